@@ -12,6 +12,6 @@ func main() {
 	pflag.CommandLine = pflag.NewFlagSet("kubectl-configset", pflag.ExitOnError)
 
 	if err := cmd.NewRootCmd().Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cmd.HandleError(err, os.Stderr))
 	}
 }
@@ -6,35 +6,35 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/wxdao/configset/pkg/configset"
-	"github.com/wxdao/configset/pkg/diffutil"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/cli-runtime/pkg/resource"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewApplyCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
-	fileNameFlags := genericclioptions.FileNameFlags{
-		Usage:     "identifying the resource.",
-		Filenames: &[]string{},
-		Recursive: func(b bool) *bool { return &b }(false),
-		Kustomize: func(s string) *string { return &s }(""),
-	}
+func NewApplyCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	fileNameFlags := newFileNameFlags()
 	forceConflictsFlag := false
 	dryRunFlag := false
 	diffFlag := false
-	stripManagedFieldsFlag := false
+	diffFlags := NewDiffFlags()
+	setLabelsFlag := map[string]string{}
+	descriptionFlag := ""
 
 	cmd := &cobra.Command{
 		Use:          "apply <name>",
 		Short:        "Apply a config set to Kubernetes.",
 		SilenceUsage: true,
 		Args:         cobra.ExactArgs(1),
-		RunE: func(c *cobra.Command, args []string) error {
+		RunE: func(c *cobra.Command, args []string) (err error) {
 			setName := args[0]
 
+			if err := diffFlags.CheckEnabled(diffFlag); err != nil {
+				return err
+			}
 			if diffFlag {
 				dryRunFlag = true
+				defer func() {
+					err = diffFlags.WrapError(err)
+				}()
 			}
 
 			restConfig, err := configFlags.ToRESTConfig()
@@ -52,34 +52,14 @@ func NewApplyCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 				return fmt.Errorf("failed to get namespace: %v", err)
 			}
 
-			fnOpt := fileNameFlags.ToOptions()
-			if err := fnOpt.RequireFilenameOrKustomize(); err != nil {
-				return err
-			}
-			builder := resource.NewLocalBuilder().
-				Unstructured().
-				Flatten().
-				NamespaceParam(namespace).DefaultNamespace().
-				FilenameParam(enforceNamespace, &fnOpt)
-
-			result := builder.Do()
-			infos, err := result.Infos()
+			objs, err := readObjects(fileNameFlags)
 			if err != nil {
-				return fmt.Errorf("failed to get resource infos: %v", err)
-			}
-			objs := make([]configset.Object, 0, len(infos))
-			for _, info := range infos {
-				obj := info.Object.(*unstructured.Unstructured)
-				if obj.GetKind() != "Namespace" && obj.GetNamespace() == "" {
-					obj.SetNamespace(namespace)
-					info.Namespace = namespace
-				}
-				objs = append(objs, obj)
+				return err
 			}
 
-			store, err := configset.NewSecretSetInfoStore(kubeClient, namespace)
+			store, err := storeFlags.ToStore(kubeClient, namespace)
 			if err != nil {
-				return fmt.Errorf("failed to create store: %v", err)
+				return err
 			}
 
 			cli, err := configset.NewClient(kubeClient, store)
@@ -87,9 +67,24 @@ func NewApplyCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 				return fmt.Errorf("failed to create configset client: %v", err)
 			}
 
+			var setLabels map[string]string
+			if c.Flags().Changed("set-labels") {
+				setLabels = setLabelsFlag
+			}
+
+			logOut := c.OutOrStdout()
+			if diffFlag && diffFlags.Structured() {
+				logOut = c.ErrOrStderr()
+			}
 			res, err := cli.Apply(c.Context(), setName, objs, configset.ApplyOptions{
-				DryRun:         dryRunFlag,
-				ForceConflicts: forceConflictsFlag,
+				Namespace:           namespace,
+				EnforceNamespace:    enforceNamespace,
+				DryRun:              dryRunFlag,
+				PopulateLiveObjects: diffFlag,
+				ForceConflicts:      forceConflictsFlag,
+				Labels:              setLabels,
+				Description:         descriptionFlag,
+				Provenance:          newProvenance(configFlags, *fileNameFlags.Filenames, *fileNameFlags.Recursive),
 				LogObjectResultFunc: func(objRes configset.ObjectResult) {
 					gvk := objRes.Config.GetObjectKind().GroupVersionKind()
 					kind := strings.ToLower(gvk.Kind)
@@ -100,7 +95,7 @@ func NewApplyCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 					if objRes.Error != nil {
 						errStr = fmt.Sprintf(" - error: %s", objRes.Error.Error())
 					}
-					fmt.Fprintf(c.OutOrStdout(), "%s: %s/%s%s\n", objRes.Action, kind, objRes.Config.GetName(), errStr)
+					fmt.Fprintf(logOut, "%s: %s/%s%s\n", objRes.Action, kind, objRes.Config.GetName(), errStr)
 				},
 			})
 			if err != nil {
@@ -108,19 +103,10 @@ func NewApplyCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 			}
 
 			if diffFlag {
-				differ, err := diffutil.NewDiffer()
-				if err != nil {
-					return fmt.Errorf("failed to create differ: %v", err)
-				}
-				defer differ.Cleanup()
-
-				if err := configset.AddObjectResultsToDiffer(res.ObjectResults, differ, configset.AddObjectResultsToDifferOptions{
-					StripManagedFields: stripManagedFieldsFlag,
-				}); err != nil {
-					return fmt.Errorf("failed to write object results to differ: %v", err)
+				if err := diffFlags.WriteReport(res.ObjectResults, setName, currentContext(configFlags), namespace); err != nil {
+					return err
 				}
-
-				if err := differ.Run(diffProgram(), c.OutOrStdout(), c.ErrOrStderr()); err != nil {
+				if err := diffFlags.Run(res.ObjectResults, c.OutOrStdout(), c.ErrOrStderr()); err != nil {
 					return err
 				}
 			}
@@ -132,8 +118,10 @@ func NewApplyCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 	fileNameFlags.AddFlags(cmd.Flags())
 	cmd.Flags().BoolVar(&forceConflictsFlag, "force-conflicts", false, "If true, apply will force the changes against conflicts.")
 	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "If true, submit server-side request without persisting the resource.")
-	cmd.Flags().BoolVar(&diffFlag, "diff", false, "If true, dry run and compares changes. Use 'KUBECTL_EXTERNAL_DIFF' to specify a custom differ, default being '"+defaultDiffProgram+"'.")
-	cmd.Flags().BoolVar(&stripManagedFieldsFlag, "strip-managed-fields", false, "If true, strip managed fields when comparing changes.")
+	cmd.Flags().BoolVar(&diffFlag, "diff", false, "If true, dry run and compares changes. Use 'KUBECTL_EXTERNAL_DIFF' to specify an external differ, e.g. 'diff -N -u', instead of the built-in one.")
+	diffFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringToStringVar(&setLabelsFlag, "set-labels", nil, "Labels of the config set, replacing existing ones. Existing labels are kept if not specified.")
+	cmd.Flags().StringVar(&descriptionFlag, "description", "", "Description of the config set. The existing description is kept if not specified.")
 
 	return cmd
 }
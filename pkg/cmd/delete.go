@@ -6,26 +6,31 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/wxdao/configset/pkg/configset"
-	"github.com/wxdao/configset/pkg/diffutil"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
 	dryRunFlag := false
 	diffFlag := false
-	stripManagedFieldsFlag := false
+	diffFlags := NewDiffFlags()
 
 	cmd := &cobra.Command{
 		Use:          "delete <name>",
 		Short:        "Delete a config set from Kubernetes.",
 		SilenceUsage: true,
 		Args:         cobra.ExactArgs(1),
-		RunE: func(c *cobra.Command, args []string) error {
+		RunE: func(c *cobra.Command, args []string) (err error) {
 			setName := args[0]
 
+			if err := diffFlags.CheckEnabled(diffFlag); err != nil {
+				return err
+			}
 			if diffFlag {
 				dryRunFlag = true
+				defer func() {
+					err = diffFlags.WrapError(err)
+				}()
 			}
 
 			restConfig, err := configFlags.ToRESTConfig()
@@ -43,9 +48,9 @@ func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 				return fmt.Errorf("failed to get namespace: %v", err)
 			}
 
-			store, err := configset.NewSecretSetInfoStore(kubeClient, namespace)
+			store, err := storeFlags.ToStore(kubeClient, namespace)
 			if err != nil {
-				return fmt.Errorf("failed to create store: %v", err)
+				return err
 			}
 
 			cli, err := configset.NewClient(kubeClient, store)
@@ -53,8 +58,13 @@ func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 				return fmt.Errorf("failed to create configset client: %v", err)
 			}
 
+			logOut := c.OutOrStdout()
+			if diffFlag && diffFlags.Structured() {
+				logOut = c.ErrOrStderr()
+			}
 			res, err := cli.Delete(c.Context(), setName, configset.DeleteOptions{
-				DryRun: dryRunFlag,
+				DryRun:              dryRunFlag,
+				PopulateLiveObjects: diffFlag,
 				LogObjectResultFunc: func(objRes configset.ObjectResult) {
 					gvk := objRes.Config.GetObjectKind().GroupVersionKind()
 					kind := strings.ToLower(gvk.Kind)
@@ -65,7 +75,7 @@ func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 					if objRes.Error != nil {
 						errStr = fmt.Sprintf(" - error: %s", objRes.Error.Error())
 					}
-					fmt.Fprintf(c.OutOrStdout(), "%s: %s/%s%s\n", objRes.Action, kind, objRes.Config.GetName(), errStr)
+					fmt.Fprintf(logOut, "%s: %s/%s%s\n", objRes.Action, kind, objRes.Config.GetName(), errStr)
 				},
 			})
 			if err != nil {
@@ -73,19 +83,10 @@ func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 			}
 
 			if diffFlag {
-				differ, err := diffutil.NewDiffer()
-				if err != nil {
-					return fmt.Errorf("failed to create differ: %v", err)
-				}
-				defer differ.Cleanup()
-
-				if err := configset.AddObjectResultsToDiffer(res.ObjectResults, differ, configset.AddObjectResultsToDifferOptions{
-					StripManagedFields: stripManagedFieldsFlag,
-				}); err != nil {
-					return fmt.Errorf("failed to write object results to differ: %v", err)
+				if err := diffFlags.WriteReport(res.ObjectResults, setName, currentContext(configFlags), namespace); err != nil {
+					return err
 				}
-
-				if err := differ.Run(diffProgram(), c.OutOrStdout(), c.ErrOrStderr()); err != nil {
+				if err := diffFlags.Run(res.ObjectResults, c.OutOrStdout(), c.ErrOrStderr()); err != nil {
 					return err
 				}
 			}
@@ -95,8 +96,8 @@ func NewDeleteCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "If true, submit server-side request without persisting the resource.")
-	cmd.Flags().BoolVar(&diffFlag, "diff", false, "If true, dry run and compares changes. Use 'KUBECTL_EXTERNAL_DIFF' to specify a custom differ, default being '"+defaultDiffProgram+"'.")
-	cmd.Flags().BoolVar(&stripManagedFieldsFlag, "strip-managed-fields", false, "If true, strip managed fields when comparing changes.")
+	cmd.Flags().BoolVar(&diffFlag, "diff", false, "If true, dry run and compares changes. Use 'KUBECTL_EXTERNAL_DIFF' to specify an external differ, e.g. 'diff -N -u', instead of the built-in one.")
+	diffFlags.AddFlags(cmd.Flags())
 
 	return cmd
 }
@@ -2,15 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
-	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewDescribeCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+func NewDescribeCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:          "describe",
 		Short:        "Describe a config set from Kubernetes.",
@@ -34,9 +36,9 @@ func NewDescribeCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 				return fmt.Errorf("failed to get namespace: %v", err)
 			}
 
-			store, err := configset.NewSecretSetInfoStore(kubeClient, namespace)
+			store, err := storeFlags.ToStore(kubeClient, namespace)
 			if err != nil {
-				return fmt.Errorf("failed to create store: %v", err)
+				return err
 			}
 
 			info, err := store.GetSetInfo(c.Context(), setName)
@@ -52,8 +54,31 @@ func NewDescribeCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 			defer tw.Flush()
 
 			fmt.Fprintf(tw, "Name:\t%s\n", info.Name)
+			fmt.Fprintf(tw, "Description:\t%s\n", info.Description)
+			fmt.Fprintf(tw, "Labels:\t%s\n", labels.FormatLabels(info.Labels))
 			fmt.Fprintf(tw, "Updated At:\t%s\n", info.UpdatedAt)
 			fmt.Fprintf(tw, "No. resources:\t%d\n", len(info.Resources))
+			if p := info.Provenance; p != nil {
+				fmt.Fprintf(tw, "Provenance:\n")
+				fmt.Fprintf(tw, "\tKubeconfig User:\t%s\n", p.KubeconfigUser)
+				fmt.Fprintf(tw, "\tConfigset Version:\t%s\n", p.ConfigsetVersion)
+				fmt.Fprintf(tw, "\tCommand Line:\t%s\n", strings.Join(p.CommandLine, " "))
+				if p.GitCommit != "" {
+					fmt.Fprintf(tw, "\tGit Commit:\t%s\n", p.GitCommit)
+					fmt.Fprintf(tw, "\tGit Dirty:\t%t\n", p.GitDirty)
+				}
+				if len(p.FileDigests) > 0 {
+					fmt.Fprintf(tw, "\tFiles:\n")
+					files := make([]string, 0, len(p.FileDigests))
+					for f := range p.FileDigests {
+						files = append(files, f)
+					}
+					sort.Strings(files)
+					for _, f := range files {
+						fmt.Fprintf(tw, "\t\t%s:\tsha256:%s\n", f, p.FileDigests[f])
+					}
+				}
+			}
 			fmt.Fprintf(tw, "Resources:\n")
 			for _, r := range info.Resources {
 				fmt.Fprintf(tw, "\t%s/%s\n", r.Namespace, r.Name)
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func NewDiffCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	fileNameFlags := newFileNameFlags()
+	forceConflictsFlag := false
+	diffFlags := NewDiffFlags()
+
+	cmd := &cobra.Command{
+		Use:   "diff <name>",
+		Short: "Compare local configs of a config set against the live objects.",
+		Long: `Compare local configs of a config set against the live objects.
+
+The configs are applied with a server-side dry run, so the diff shows what apply would change, including the objects it would prune.
+
+Stored revisions of a config set can't be compared with each other: config set info records only the identity of each resource, not the applied configs, so there is no history to diff offline.`,
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) (err error) {
+			setName := args[0]
+
+			defer func() {
+				err = diffFlags.WrapError(err)
+			}()
+
+			restConfig, err := configFlags.ToRESTConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get rest config: %v", err)
+			}
+
+			kubeClient, err := crclient.New(restConfig, crclient.Options{})
+			if err != nil {
+				return fmt.Errorf("failed to create kube client: %w", err)
+			}
+
+			namespace, enforceNamespace, err := configFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to get namespace: %v", err)
+			}
+
+			objs, err := readObjects(fileNameFlags)
+			if err != nil {
+				return err
+			}
+
+			store, err := storeFlags.ToStore(kubeClient, namespace)
+			if err != nil {
+				return err
+			}
+
+			cli, err := configset.NewClient(kubeClient, store)
+			if err != nil {
+				return fmt.Errorf("failed to create configset client: %v", err)
+			}
+
+			res, err := cli.Apply(c.Context(), setName, objs, configset.ApplyOptions{
+				Namespace:           namespace,
+				EnforceNamespace:    enforceNamespace,
+				DryRun:              true,
+				PopulateLiveObjects: true,
+				ForceConflicts:      forceConflictsFlag,
+				LogObjectResultFunc: func(objRes configset.ObjectResult) {
+					if objRes.Error == nil {
+						return
+					}
+					gvk := objRes.Config.GetObjectKind().GroupVersionKind()
+					kind := strings.ToLower(gvk.Kind)
+					if gvk.Group != "" {
+						kind = kind + "." + strings.ToLower(gvk.Group)
+					}
+					fmt.Fprintf(c.ErrOrStderr(), "%s: %s/%s - error: %s\n", objRes.Action, kind, objRes.Config.GetName(), objRes.Error.Error())
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := diffFlags.WriteReport(res.ObjectResults, setName, currentContext(configFlags), namespace); err != nil {
+				return err
+			}
+			return diffFlags.Run(res.ObjectResults, c.OutOrStdout(), c.ErrOrStderr())
+		},
+	}
+
+	fileNameFlags.AddFlags(cmd.Flags())
+	cmd.Flags().BoolVar(&forceConflictsFlag, "force-conflicts", false, "If true, compare the changes as if forced against conflicts.")
+	diffFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/wxdao/configset/pkg/configset"
+	"github.com/wxdao/configset/pkg/diffutil"
+	"golang.org/x/term"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	diffOutputUnified = "unified"
+	diffOutputJSON    = "json"
+	diffOutputYAML    = "yaml"
+)
+
+const (
+	diffProfileClean = "clean"
+	diffProfileNone  = "none"
+)
+
+const (
+	diffColorAuto   = "auto"
+	diffColorAlways = "always"
+	diffColorNever  = "never"
+)
+
+type DiffFlags struct {
+	Output *string
+	Stat   *string
+
+	Report     *string
+	ReportFile *string
+	Color      *string
+	Context    *int
+	Headers    *bool
+
+	IgnoreDifferences     *[]string
+	IgnoreDifferencesFile *string
+	ShowSecrets           *bool
+	OwnedFieldsOnly       *bool
+	ExitCode              *bool
+
+	Profile                *string
+	StripManagedFields     *bool
+	StripGeneration        *bool
+	StripResourceVersion   *bool
+	StripUID               *bool
+	StripCreationTimestamp *bool
+	StripStatus            *bool
+
+	flags *pflag.FlagSet
+}
+
+func NewDiffFlags() *DiffFlags {
+	return &DiffFlags{
+		Output: func(s string) *string { return &s }(diffOutputUnified),
+		Stat:   func(s string) *string { return &s }(""),
+
+		Report:     func(s string) *string { return &s }(""),
+		ReportFile: func(s string) *string { return &s }(""),
+		Color:      func(s string) *string { return &s }(diffColorAuto),
+		Context:    func(i int) *int { return &i }(diffutil.DefaultContextLines),
+		Headers:    func(b bool) *bool { return &b }(true),
+
+		IgnoreDifferences:     &[]string{},
+		IgnoreDifferencesFile: func(s string) *string { return &s }(""),
+		ShowSecrets:           func(b bool) *bool { return &b }(false),
+		OwnedFieldsOnly:       func(b bool) *bool { return &b }(false),
+		ExitCode:              func(b bool) *bool { return &b }(false),
+
+		Profile:                func(s string) *string { return &s }(diffProfileClean),
+		StripManagedFields:     func(b bool) *bool { return &b }(false),
+		StripGeneration:        func(b bool) *bool { return &b }(false),
+		StripResourceVersion:   func(b bool) *bool { return &b }(false),
+		StripUID:               func(b bool) *bool { return &b }(false),
+		StripCreationTimestamp: func(b bool) *bool { return &b }(false),
+		StripStatus:            func(b bool) *bool { return &b }(false),
+	}
+}
+
+func (f *DiffFlags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(f.Output, "diff-output", *f.Output, "The format of the diff. One of: "+diffOutputUnified+", "+diffOutputJSON+", "+diffOutputYAML+". The latter two report changed field paths per object.")
+	flags.StringVar(f.Stat, "diff-stat", *f.Stat, "If set, show a summary of the changes of each object instead of the diff, formatted as one of: "+diffStatTable+" (the default), "+diffStatJSON+", "+diffStatMarkdown+", e.g. --diff-stat=markdown.")
+	flags.Lookup("diff-stat").NoOptDefVal = diffStatTable
+	flags.StringVar(f.Report, "diff-report", *f.Report, "If set, also write a report of the changes to --diff-report-file, formatted as one of: "+string(configset.DiffReportMarkdown)+", "+string(configset.DiffReportHTML)+".")
+	flags.StringVar(f.ReportFile, "diff-report-file", *f.ReportFile, "The file to write the report of --diff-report to.")
+	flags.StringVar(f.Color, "diff-color", *f.Color, "When to color the diff. One of: "+diffColorAuto+", "+diffColorAlways+", "+diffColorNever+". Ignored with 'KUBECTL_EXTERNAL_DIFF'.")
+	flags.IntVar(f.Context, "diff-context", *f.Context, "The number of unchanged lines shown around changes in the diff. Ignored with 'KUBECTL_EXTERNAL_DIFF'.")
+	flags.BoolVar(f.Headers, "diff-headers", *f.Headers, "If true, show a header naming the resource before each diff. Ignored with 'KUBECTL_EXTERNAL_DIFF'.")
+	flags.BoolVar(f.ExitCode, "exit-code", *f.ExitCode, "If true, exit with status 1 if the diff has changes. The status is 0 if there are none, and greater than 1 on errors.")
+	flags.BoolVar(f.ShowSecrets, "show-secrets", *f.ShowSecrets, "If true, show the values of Secrets in the diff instead of masking them.")
+	flags.BoolVar(f.OwnedFieldsOnly, "owned-fields-only", *f.OwnedFieldsOnly, "If true, compare only the fields applied by configset, as recorded in managed fields, and show the fields it starts or stops owning.")
+	flags.StringArrayVar(f.IgnoreDifferences, "ignore-differences", *f.IgnoreDifferences, "A field to leave out of the diff, as <kind>[.<group>][/<name pattern>]=<path>, the path being a JSON pointer if it starts with '/' or a JSONPath otherwise, e.g. 'deployment.apps=/spec/replicas'. Kind may be '*'. Can be repeated.")
+	flags.StringVar(f.IgnoreDifferencesFile, "ignore-differences-file", *f.IgnoreDifferencesFile, "A YAML file with a list of rules of fields to leave out of the diff, each having optional group, kind, namespace and name patterns, and jsonPointers and jsonPaths.")
+
+	flags.StringVar(f.Profile, "diff-profile", *f.Profile, "The fields stripped before comparing changes, which the --strip-* flags override. One of: "+diffProfileClean+" (uid, creationTimestamp, resourceVersion, generation and managedFields, and the duplicated metrics of autoscaling/v2beta2 HorizontalPodAutoscalers), "+diffProfileNone+".")
+	flags.BoolVar(f.StripManagedFields, "strip-managed-fields", *f.StripManagedFields, "If true, strip managed fields when comparing changes.")
+	flags.BoolVar(f.StripGeneration, "strip-generation", *f.StripGeneration, "If true, strip generation when comparing changes.")
+	flags.BoolVar(f.StripResourceVersion, "strip-resource-version", *f.StripResourceVersion, "If true, strip resource version when comparing changes.")
+	flags.BoolVar(f.StripUID, "strip-uid", *f.StripUID, "If true, strip uid when comparing changes.")
+	flags.BoolVar(f.StripCreationTimestamp, "strip-creation-timestamp", *f.StripCreationTimestamp, "If true, strip creation timestamp when comparing changes.")
+	flags.BoolVar(f.StripStatus, "strip-status", *f.StripStatus, "If true, strip status when comparing changes.")
+
+	f.flags = flags
+}
+
+// ToOptions returns the normalization options from the profile, overridden by
+// the --strip-* flags given explicitly. If the flags were never added to a
+// flag set, the --strip-* fields that are true are added to the profile.
+func (f *DiffFlags) ToOptions() (configset.AddObjectResultsToDifferOptions, error) {
+	var opt configset.AddObjectResultsToDifferOptions
+	switch *f.Profile {
+	case diffProfileClean:
+		opt = configset.CleanDiffOptions()
+	case diffProfileNone:
+	default:
+		return opt, fmt.Errorf("invalid --diff-profile %q", *f.Profile)
+	}
+
+	override := func(name string, flag *bool, option *bool) {
+		if f.flags != nil && f.flags.Changed(name) || f.flags == nil && *flag {
+			*option = *flag
+		}
+	}
+	override("strip-managed-fields", f.StripManagedFields, &opt.StripManagedFields)
+	override("strip-generation", f.StripGeneration, &opt.StripGeneration)
+	override("strip-resource-version", f.StripResourceVersion, &opt.StripResourceVersion)
+	override("strip-uid", f.StripUID, &opt.StripUID)
+	override("strip-creation-timestamp", f.StripCreationTimestamp, &opt.StripCreationTimestamp)
+	override("strip-status", f.StripStatus, &opt.StripStatus)
+
+	rules, err := f.ignoreDifferencesRules()
+	if err != nil {
+		return opt, err
+	}
+	opt.IgnoreDifferences = rules
+	opt.ShowSecrets = *f.ShowSecrets
+	opt.OwnedFieldsOnly = *f.OwnedFieldsOnly
+	return opt, nil
+}
+
+// WriteReport writes the report of results to the file if --diff-report is
+// set, the set name, kubeconfig context and namespace being shown in its
+// header.
+func (f *DiffFlags) WriteReport(results []configset.ObjectResult, setName string, context string, namespace string) error {
+	if *f.Report == "" {
+		return nil
+	}
+	if *f.ReportFile == "" {
+		return fmt.Errorf("--diff-report-file is required with --diff-report")
+	}
+	opt, err := f.ToOptions()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := configset.WriteDiffReport(&buf, results, configset.DiffReportOptions{
+		Format:       configset.DiffReportFormat(*f.Report),
+		SetName:      setName,
+		Context:      context,
+		Namespace:    namespace,
+		ContextLines: *f.Context,
+		DiffOptions:  opt,
+	}); err != nil {
+		return fmt.Errorf("failed to render diff report: %v", err)
+	}
+	if err := os.WriteFile(*f.ReportFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write diff report: %v", err)
+	}
+	return nil
+}
+
+// CheckEnabled fails if flags producing diff output or exit codes are given
+// while diffs are not enabled, e.g. without --diff, rather than ignoring them.
+func (f *DiffFlags) CheckEnabled(enabled bool) error {
+	if enabled || f.flags == nil {
+		return nil
+	}
+	for _, name := range []string{"diff-report", "diff-report-file", "diff-stat", "exit-code"} {
+		if f.flags.Changed(name) {
+			return fmt.Errorf("--%s requires --diff", name)
+		}
+	}
+	return nil
+}
+
+// Structured tells whether the diff is machine-readable, in which case other
+// output should not go to stdout.
+func (f *DiffFlags) Structured() bool {
+	if *f.Stat != "" {
+		return *f.Stat != diffStatTable
+	}
+	return *f.Output == diffOutputJSON || *f.Output == diffOutputYAML
+}
+
+// Run shows the diff of results in the format from the flags. Unified diffs
+// are shown with the external differ from 'KUBECTL_EXTERNAL_DIFF' if set, or
+// with the built-in one otherwise. With --exit-code, an ExitError with
+// ExitCodeChanges is returned if there are changes.
+func (f *DiffFlags) Run(results []configset.ObjectResult, stdout io.Writer, stderr io.Writer) error {
+	opt, err := f.ToOptions()
+	if err != nil {
+		return err
+	}
+	changed, err := f.show(results, opt, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if changed && *f.ExitCode {
+		return &ExitError{Code: ExitCodeChanges}
+	}
+	return nil
+}
+
+// WrapError makes err exit with ExitCodeError, so that errors can be told
+// from changes.
+func (f *DiffFlags) WrapError(err error) error {
+	var exitErr *ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: ExitCodeError, Err: err}
+}
+
+// show shows the diff and returns whether there are changes.
+func (f *DiffFlags) show(results []configset.ObjectResult, opt configset.AddObjectResultsToDifferOptions, stdout io.Writer, stderr io.Writer) (bool, error) {
+	if *f.Stat != "" {
+		stat, err := configset.DiffStatObjectResults(results, opt)
+		if err != nil {
+			return false, fmt.Errorf("failed to summarize diff: %v", err)
+		}
+		if err := printDiffStat(stdout, stat, *f.Stat); err != nil {
+			return false, err
+		}
+		totals := stat.Totals
+		return totals.Create+totals.Update+totals.Delete > 0, nil
+	}
+
+	switch *f.Output {
+	case diffOutputUnified:
+	case diffOutputJSON, diffOutputYAML:
+		diffs, err := configset.DiffObjectResults(results, opt)
+		if err != nil {
+			return false, fmt.Errorf("failed to diff object results: %v", err)
+		}
+		var b []byte
+		if *f.Output == diffOutputJSON {
+			b, err = json.MarshalIndent(diffs, "", "  ")
+			b = append(b, '\n')
+		} else {
+			b, err = yaml.Marshal(diffs)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal diff: %v", err)
+		}
+		if _, err := stdout.Write(b); err != nil {
+			return false, err
+		}
+		for _, diff := range diffs {
+			if diff.Action != configset.DiffActionUnchanged {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid --diff-output %q", *f.Output)
+	}
+
+	differ, err := diffutil.NewDiffer()
+	if err != nil {
+		return false, fmt.Errorf("failed to create differ: %v", err)
+	}
+	defer differ.Cleanup()
+
+	if err := configset.AddObjectResultsToDiffer(results, differ, opt); err != nil {
+		return false, fmt.Errorf("failed to write object results to differ: %v", err)
+	}
+
+	if program := os.Getenv("KUBECTL_EXTERNAL_DIFF"); program != "" {
+		// diff programs exit with 1 if there are changes
+		err := differ.Run(program, stdout, stderr)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to run diff program: %w", err)
+		}
+		return false, nil
+	}
+
+	color, err := f.color(stdout)
+	if err != nil {
+		return false, err
+	}
+	changed, err := differ.Render(stdout, diffutil.RenderOptions{
+		Context: *f.Context,
+		Color:   color,
+		Headers: *f.Headers,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to render diff: %v", err)
+	}
+	return changed, nil
+}
+
+func (f *DiffFlags) color(out io.Writer) (bool, error) {
+	switch *f.Color {
+	case diffColorAlways:
+		return true, nil
+	case diffColorNever:
+		return false, nil
+	case diffColorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		file, ok := out.(*os.File)
+		return ok && term.IsTerminal(int(file.Fd())), nil
+	default:
+		return false, fmt.Errorf("invalid --diff-color %q", *f.Color)
+	}
+}
+
+func (f *DiffFlags) ignoreDifferencesRules() ([]configset.IgnoreDifferencesRule, error) {
+	var rules []configset.IgnoreDifferencesRule
+	if *f.IgnoreDifferencesFile != "" {
+		b, err := os.ReadFile(*f.IgnoreDifferencesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ignore differences file: %v", err)
+		}
+		if err := yaml.UnmarshalStrict(b, &rules); err != nil {
+			return nil, fmt.Errorf("failed to parse ignore differences file: %v", err)
+		}
+	}
+	for _, arg := range *f.IgnoreDifferences {
+		rule, err := parseIgnoreDifferencesRule(arg)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid ignore differences rule: %v", err)
+		}
+	}
+	return rules, nil
+}
+
+// parseIgnoreDifferencesRule parses <kind>[.<group>][/<name pattern>]=<path>.
+func parseIgnoreDifferencesRule(arg string) (configset.IgnoreDifferencesRule, error) {
+	var rule configset.IgnoreDifferencesRule
+	target, path, ok := strings.Cut(arg, "=")
+	if !ok || target == "" || path == "" {
+		return rule, fmt.Errorf("invalid --ignore-differences %q, expected <kind>[.<group>][/<name pattern>]=<path>", arg)
+	}
+	kind, name, _ := strings.Cut(target, "/")
+	if k, group, ok := strings.Cut(kind, "."); ok {
+		kind = k
+		rule.Group = group
+	}
+	rule.Kind = kind
+	rule.Name = name
+	if strings.HasPrefix(path, "/") {
+		rule.JSONPointers = []string{path}
+	} else {
+		rule.JSONPaths = []string{path}
+	}
+	return rule, nil
+}
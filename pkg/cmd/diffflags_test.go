@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/wxdao/configset/pkg/configset"
+)
+
+func TestDiffFlagsToOptions(t *testing.T) {
+	withStatus := configset.CleanDiffOptions()
+	withStatus.StripStatus = true
+	withoutUID := configset.CleanDiffOptions()
+	withoutUID.StripUID = false
+
+	tests := []struct {
+		name string
+		args []string
+		// set changes the fields instead of parsing args if not nil
+		set  func(f *DiffFlags)
+		want configset.AddObjectResultsToDifferOptions
+	}{
+		{
+			name: "default",
+			want: configset.CleanDiffOptions(),
+		},
+		{
+			name: "no profile",
+			args: []string{"--diff-profile=none", "--strip-status"},
+			want: configset.AddObjectResultsToDifferOptions{StripStatus: true},
+		},
+		{
+			name: "override",
+			args: []string{"--strip-uid=false"},
+			want: withoutUID,
+		},
+		{
+			name: "without flag set",
+			set: func(f *DiffFlags) {
+				*f.StripStatus = true
+			},
+			want: withStatus,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewDiffFlags()
+			if tt.set != nil {
+				tt.set(f)
+			} else {
+				flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+				f.AddFlags(flags)
+				if err := flags.Parse(tt.args); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := f.ToOptions()
+			if err != nil {
+				t.Fatalf("ToOptions: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	diffStatTable    = "table"
+	diffStatJSON     = "json"
+	diffStatMarkdown = "markdown"
+)
+
+func printDiffStat(out io.Writer, stat configset.DiffStat, format string) error {
+	switch format {
+	case diffStatTable:
+		tw := tabwriter.NewWriter(out, 0, 0, 5, ' ', 0)
+		fmt.Fprintf(tw, "ACTION\tRESOURCE\tNAMESPACE\tADDED\tREMOVED\n")
+		for _, obj := range stat.Objects {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t+%d\t-%d\n", obj.Action, diffStatResource(obj), obj.Namespace, obj.Added, obj.Removed)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out, diffStatSummary(stat.Totals))
+		return err
+	case diffStatJSON:
+		b, err := json.MarshalIndent(stat, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff stat: %v", err)
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	case diffStatMarkdown:
+		var sb strings.Builder
+		sb.WriteString("| Action | Resource | Namespace | Added | Removed |\n")
+		sb.WriteString("| --- | --- | --- | ---: | ---: |\n")
+		for _, obj := range stat.Objects {
+			fmt.Fprintf(&sb, "| %s | `%s` | %s | +%d | -%d |\n", obj.Action, diffStatResource(obj), obj.Namespace, obj.Added, obj.Removed)
+		}
+		fmt.Fprintf(&sb, "\n**%s**\n", diffStatSummary(stat.Totals))
+		_, err := io.WriteString(out, sb.String())
+		return err
+	default:
+		return fmt.Errorf("invalid --diff-stat %q", format)
+	}
+}
+
+// diffStatResource names the object of stat as kind[.group]/name.
+func diffStatResource(stat configset.ObjectDiffStat) string {
+	gvk := schema.FromAPIVersionAndKind(stat.APIVersion, stat.Kind)
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind = kind + "." + strings.ToLower(gvk.Group)
+	}
+	return kind + "/" + stat.Name
+}
+
+func diffStatSummary(totals configset.DiffStatTotals) string {
+	return fmt.Sprintf("%d to create, %d to update, %d to delete, %d unchanged, %d lines added, %d lines removed",
+		totals.Create, totals.Update, totals.Delete, totals.Unchanged, totals.Added, totals.Removed)
+}
@@ -6,11 +6,15 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewListCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+func NewListCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	allNamespacesFlag := false
+	selectorFlag := ""
+
 	cmd := &cobra.Command{
 		Use:          "list",
 		Short:        "List all config sets from Kubernetes.",
@@ -31,21 +35,52 @@ func NewListCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 				return fmt.Errorf("failed to get namespace: %v", err)
 			}
 
-			store, err := configset.NewSecretSetInfoStore(kubeClient, namespace)
+			store, err := storeFlags.ToStore(kubeClient, namespace)
 			if err != nil {
-				return fmt.Errorf("failed to create store: %v", err)
+				return err
 			}
 
-			infos, err := store.ListSetInfos(c.Context())
+			selector, err := labels.Parse(selectorFlag)
 			if err != nil {
-				return fmt.Errorf("failed to list set infos: %v", err)
+				return fmt.Errorf("failed to parse selector: %v", err)
 			}
 
 			tw := tabwriter.NewWriter(c.OutOrStdout(), 0, 0, 5, ' ', 0)
 			defer tw.Flush()
 
+			if allNamespacesFlag {
+				lister, ok := store.(configset.AllNamespacesSetInfoLister)
+				if !ok {
+					return fmt.Errorf("store does not support listing across namespaces")
+				}
+				infos, skipped, err := lister.ListAllNamespacesSetInfos(c.Context())
+				if err != nil {
+					return fmt.Errorf("failed to list set infos: %v", err)
+				}
+				for _, ns := range skipped {
+					fmt.Fprintf(c.ErrOrStderr(), "warning: skipped namespace %s: access denied\n", ns)
+				}
+
+				tw.Write([]byte("NAMESPACE\tNAME\tNO. RESOURCES\tUPDATED AT\n"))
+				for _, info := range infos {
+					if !selector.Matches(labels.Set(info.Labels)) {
+						continue
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", info.Namespace, info.Name, len(info.Resources), info.UpdatedAt)
+				}
+				return nil
+			}
+
+			infos, err := store.ListSetInfos(c.Context())
+			if err != nil {
+				return fmt.Errorf("failed to list set infos: %v", err)
+			}
+
 			tw.Write([]byte("NAME\tNO. RESOURCES\tUPDATED AT\n"))
 			for _, info := range infos {
+				if !selector.Matches(labels.Set(info.Labels)) {
+					continue
+				}
 				fmt.Fprintf(tw, "%s\t%d\t%s\n", info.Name, len(info.Resources), info.UpdatedAt)
 			}
 
@@ -53,5 +88,8 @@ func NewListCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVarP(&selectorFlag, "selector", "l", "", "Selector (label query) to filter config sets on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
+	cmd.Flags().BoolVarP(&allNamespacesFlag, "all-namespaces", "A", false, "If true, list config sets across all namespaces.")
+
 	return cmd
 }
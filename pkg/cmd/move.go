@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func NewMoveCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	dryRunFlag := false
+
+	cmd := &cobra.Command{
+		Use:          "move <from> <to> <kind[.group]/name>...",
+		Short:        "Move resources from a config set to another without touching them.",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(3),
+		RunE: func(c *cobra.Command, args []string) error {
+			from, to := args[0], args[1]
+
+			restConfig, err := configFlags.ToRESTConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get rest config: %v", err)
+			}
+
+			kubeClient, err := crclient.New(restConfig, crclient.Options{})
+			if err != nil {
+				return fmt.Errorf("failed to create kube client: %w", err)
+			}
+
+			namespace, enforceNamespace, err := configFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to get namespace: %v", err)
+			}
+
+			refs := make([]configset.ResourceRef, 0, len(args)-2)
+			for _, arg := range args[2:] {
+				kind, name, ok := strings.Cut(arg, "/")
+				if !ok || kind == "" || name == "" {
+					return fmt.Errorf("invalid resource %q, expecting <kind[.group]>/<name>", arg)
+				}
+				ref := configset.ResourceRef{Kind: kind, Name: name}
+				if k, group, ok := strings.Cut(kind, "."); ok {
+					ref.Kind, ref.Group = k, group
+				}
+				if enforceNamespace {
+					ref.Namespace = namespace
+				}
+				refs = append(refs, ref)
+			}
+
+			store, err := storeFlags.ToStore(kubeClient, namespace)
+			if err != nil {
+				return err
+			}
+
+			cli, err := configset.NewClient(kubeClient, store)
+			if err != nil {
+				return fmt.Errorf("failed to create configset client: %v", err)
+			}
+
+			moved, err := cli.Move(c.Context(), from, to, refs, configset.MoveOptions{
+				DryRun: dryRunFlag,
+			})
+			if err != nil {
+				return err
+			}
+
+			action := "moved"
+			if dryRunFlag {
+				action = "would move"
+			}
+			for _, r := range moved {
+				fmt.Fprintf(c.OutOrStdout(), "%s: %s/%s (namespace %q) %s -> %s\n", action, strings.ToLower(r.Kind), r.Name, r.Namespace, from, to)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "If true, only check that the resources can be moved.")
+
+	return cmd
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Version is the version of configset, set at build time with
+// -ldflags "-X github.com/wxdao/configset/pkg/cmd.Version=...".
+var Version = ""
+
+func configsetVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return ""
+}
+
+var sourceFileExtensions = []string{".json", ".yaml", ".yml"}
+
+// sensitiveFlags are the flags left out of the recorded command line, along
+// with their values, as they may hold credentials.
+var sensitiveFlags = []string{"token", "password", "username", "client-key"}
+
+// redactCommandLine returns args without the sensitive flags, given as either
+// --flag value or --flag=value.
+func redactCommandLine(args []string) []string {
+	redacted := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "--") || !lo.Contains(sensitiveFlags, name) {
+			redacted = append(redacted, args[i])
+			continue
+		}
+		if !hasValue {
+			// skip the value too
+			i++
+		}
+	}
+	return redacted
+}
+
+// newProvenance describes the current apply of the config files in filenames.
+func newProvenance(configFlags *genericclioptions.ConfigFlags, filenames []string, recursive bool) *configset.Provenance {
+	p := &configset.Provenance{
+		ConfigsetVersion: configsetVersion(),
+		CommandLine:      redactCommandLine(os.Args),
+		FileDigests:      map[string]string{},
+	}
+
+	if rawConfig, err := configFlags.ToRawKubeConfigLoader().RawConfig(); err == nil {
+		contextName := rawConfig.CurrentContext
+		if configFlags.Context != nil && *configFlags.Context != "" {
+			contextName = *configFlags.Context
+		}
+		if ctx, ok := rawConfig.Contexts[contextName]; ok {
+			p.KubeconfigUser = ctx.AuthInfo
+		}
+		if configFlags.AuthInfoName != nil && *configFlags.AuthInfoName != "" {
+			p.KubeconfigUser = *configFlags.AuthInfoName
+		}
+	}
+
+	for _, filename := range filenames {
+		if filename == "-" || strings.Contains(filename, "://") {
+			continue
+		}
+		if p.GitCommit == "" {
+			p.GitCommit, p.GitDirty = gitRevision(filename)
+		}
+		root := filename
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if path != root && !hasSourceFileExtension(path) {
+				return nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			sum := sha256.Sum256(b)
+			p.FileDigests[path] = hex.EncodeToString(sum[:])
+			return nil
+		})
+	}
+
+	return p
+}
+
+func hasSourceFileExtension(path string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range sourceFileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// gitRevision returns the commit checked out in the git work tree containing
+// path and whether the work tree has uncommitted changes, or an empty commit if
+// path isn't in a git work tree.
+func gitRevision(path string) (string, bool) {
+	dir := path
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	commit := strings.TrimSpace(string(out))
+	out, err = exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	if err != nil {
+		return commit, false
+	}
+	return commit, len(strings.TrimSpace(string(out))) > 0
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func NewRenameCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	dryRunFlag := false
+
+	cmd := &cobra.Command{
+		Use:   "rename <old-name> <new-name>",
+		Short: "Rename a config set without touching its resources.",
+		Long: `Rename a config set without touching its resources.
+
+Only the config set info moves to the new name, along with the revision and status kept by the store. The live objects are left as is: configset writes no per-set labels on them, and applies them all with the same field manager, so there is nothing to rewrite.`,
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(2),
+		RunE: func(c *cobra.Command, args []string) error {
+			oldName, newName := args[0], args[1]
+
+			restConfig, err := configFlags.ToRESTConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get rest config: %v", err)
+			}
+
+			kubeClient, err := crclient.New(restConfig, crclient.Options{})
+			if err != nil {
+				return fmt.Errorf("failed to create kube client: %w", err)
+			}
+
+			namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to get namespace: %v", err)
+			}
+
+			store, err := storeFlags.ToStore(kubeClient, namespace)
+			if err != nil {
+				return err
+			}
+
+			cli, err := configset.NewClient(kubeClient, store)
+			if err != nil {
+				return fmt.Errorf("failed to create configset client: %v", err)
+			}
+
+			if err := cli.Rename(c.Context(), oldName, newName, configset.RenameOptions{
+				DryRun: dryRunFlag,
+			}); err != nil {
+				return err
+			}
+
+			action := "renamed"
+			if dryRunFlag {
+				action = "would rename"
+			}
+			fmt.Fprintf(c.OutOrStdout(), "%s: %s -> %s\n", action, oldName, newName)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "If true, only check that the config set can be renamed.")
+
+	return cmd
+}
@@ -7,19 +7,28 @@ import (
 
 func NewRootCmd() *cobra.Command {
 	configFlags := genericclioptions.NewConfigFlags(true)
+	storeFlags := NewStoreFlags()
 
 	cmd := &cobra.Command{
 		Use:          "configset",
 		Short:        "Management of config sets for Kubernetes.",
 		SilenceUsage: true,
+		// errors are printed by HandleError
+		SilenceErrors: true,
 	}
 
 	configFlags.AddFlags(cmd.PersistentFlags())
+	storeFlags.AddFlags(cmd.PersistentFlags())
 
-	cmd.AddCommand(NewApplyCmd(configFlags))
-	cmd.AddCommand(NewDeleteCmd(configFlags))
-	cmd.AddCommand(NewListCmd(configFlags))
-	cmd.AddCommand(NewDescribeCmd(configFlags))
+	cmd.AddCommand(NewApplyCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewDeleteCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewDiffCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewListCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewDescribeCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewRenameCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewMoveCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewStoreCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewMigrateStoreCmd(configFlags, storeFlags))
 
 	return cmd
 }
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func NewStoreCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "store",
+		Short:        "Manage the stores of config set info.",
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(NewStoreMigrateCmd(configFlags, storeFlags))
+	cmd.AddCommand(NewStoreUpgradeCmd(configFlags, storeFlags))
+
+	return cmd
+}
+
+func NewStoreMigrateCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	fromFlag := storeTypeSecret
+	toFlag := storeTypeConfigMap
+	fromNamespaceFlag := ""
+	toNamespaceFlag := ""
+	deleteSourceFlag := false
+	dryRunFlag := false
+
+	cmd := &cobra.Command{
+		Use:          "migrate [name...]",
+		Short:        "Copy all or the given config set info from one store to another.",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			restConfig, err := configFlags.ToRESTConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get rest config: %v", err)
+			}
+
+			kubeClient, err := crclient.New(restConfig, crclient.Options{})
+			if err != nil {
+				return fmt.Errorf("failed to create kube client: %w", err)
+			}
+
+			namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to get namespace: %v", err)
+			}
+			namespace = storeFlags.storeNamespace(namespace)
+			if fromNamespaceFlag == "" {
+				fromNamespaceFlag = namespace
+			}
+			if toNamespaceFlag == "" {
+				toNamespaceFlag = namespace
+			}
+
+			src, err := newStore(fromFlag, kubeClient, fromNamespaceFlag)
+			if err != nil {
+				return err
+			}
+			dst, err := newStore(toFlag, kubeClient, toNamespaceFlag)
+			if err != nil {
+				return err
+			}
+
+			results, err := configset.CopySetInfos(c.Context(), src, dst, configset.CopySetInfosOptions{
+				Names:        args,
+				DryRun:       dryRunFlag,
+				DeleteSource: deleteSourceFlag,
+			})
+			for _, res := range results {
+				action := "copied"
+				if dryRunFlag {
+					action = "would copy"
+				} else if res.Deleted {
+					action = "moved"
+				}
+				overwritten := ""
+				if res.Overwritten {
+					overwritten = " (overwriting existing)"
+				}
+				fmt.Fprintf(c.OutOrStdout(), "%s: %s%s\n", action, res.Name, overwritten)
+			}
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&fromFlag, "from", fromFlag, "The store to copy config set info from.")
+	cmd.Flags().StringVar(&toFlag, "to", toFlag, "The store to copy config set info to.")
+	cmd.Flags().StringVar(&fromNamespaceFlag, "from-namespace", "", "The namespace of the source store. Defaults to the store namespace.")
+	cmd.Flags().StringVar(&toNamespaceFlag, "to-namespace", "", "The namespace of the destination store. Defaults to the store namespace.")
+	cmd.Flags().BoolVar(&deleteSourceFlag, "delete-source", false, "If true, delete config set info from the source store once copied and verified.")
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "If true, only print the config set info that would be copied.")
+
+	return cmd
+}
+
+func NewStoreUpgradeCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	dryRunFlag := false
+	backupDirFlag := ""
+
+	cmd := &cobra.Command{
+		Use:          "upgrade",
+		Short:        "Rewrite all config set info in the store with the current schema version, backing up the original.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			restConfig, err := configFlags.ToRESTConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get rest config: %v", err)
+			}
+
+			kubeClient, err := crclient.New(restConfig, crclient.Options{})
+			if err != nil {
+				return fmt.Errorf("failed to create kube client: %w", err)
+			}
+
+			namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to get namespace: %v", err)
+			}
+
+			store, err := storeFlags.ToStore(kubeClient, namespace)
+			if err != nil {
+				return err
+			}
+
+			if backupDirFlag == "" {
+				backupDirFlag = fmt.Sprintf("configset-backup-%d", time.Now().Unix())
+			}
+
+			backedUp := 0
+			results, err := configset.MigrateSetInfoSchemas(c.Context(), store, configset.MigrateSchemaOptions{
+				DryRun: dryRunFlag,
+				BackupFunc: func(name string, payload []byte) error {
+					if err := os.MkdirAll(backupDirFlag, 0700); err != nil {
+						return err
+					}
+					if err := os.WriteFile(filepath.Join(backupDirFlag, name), payload, 0600); err != nil {
+						return err
+					}
+					backedUp++
+					return nil
+				},
+			})
+			for _, res := range results {
+				if res.FromVersion == res.ToVersion {
+					fmt.Fprintf(c.OutOrStdout(), "unchanged: %s (schema version %d)\n", res.Name, res.ToVersion)
+					continue
+				}
+				action := "migrated"
+				if dryRunFlag {
+					action = "would migrate"
+				}
+				fmt.Fprintf(c.OutOrStdout(), "%s: %s (schema version %d -> %d)\n", action, res.Name, res.FromVersion, res.ToVersion)
+			}
+			if backedUp > 0 {
+				fmt.Fprintf(c.OutOrStdout(), "original set info backed up to %s\n", backupDirFlag)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "If true, only report the set info that would be migrated.")
+	cmd.Flags().StringVar(&backupDirFlag, "backup-dir", "", "The directory to back up the original set info to, created if any set info gets rewritten. Defaults to a new 'configset-backup-<timestamp>' directory in the current working directory.")
+
+	return cmd
+}
+
+// NewMigrateStoreCmd is store upgrade under its former name.
+func NewMigrateStoreCmd(configFlags *genericclioptions.ConfigFlags, storeFlags *StoreFlags) *cobra.Command {
+	cmd := NewStoreUpgradeCmd(configFlags, storeFlags)
+	cmd.Use = "migrate-store"
+	cmd.Short = "Same as 'store upgrade'. " + cmd.Short
+	return cmd
+}
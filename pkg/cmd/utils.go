@@ -1,18 +1,156 @@
 package cmd
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ExitCodeChanges is the exit status of diffs with changes under --exit-code.
+	ExitCodeChanges = 1
+	// ExitCodeError is the exit status of errors that must not be mistaken for
+	// changes.
+	ExitCodeError = 2
+)
+
+// ExitError makes a command exit with Code. Nothing is printed if Err is nil.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// HandleError prints err if needed and returns the exit status for it.
+func HandleError(err error, stderr io.Writer) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.Err != nil {
+			fmt.Fprintln(stderr, "Error:", exitErr.Err)
+		}
+		return exitErr.Code
+	}
+	fmt.Fprintln(stderr, "Error:", err)
+	return 1
+}
+
+func newFileNameFlags() genericclioptions.FileNameFlags {
+	return genericclioptions.FileNameFlags{
+		Usage:     "identifying the resource.",
+		Filenames: &[]string{},
+		Recursive: func(b bool) *bool { return &b }(false),
+		Kustomize: func(s string) *string { return &s }(""),
+	}
+}
+
+// readObjects reads the objects from the files or kustomization directory
+// given by fileNameFlags.
+func readObjects(fileNameFlags genericclioptions.FileNameFlags) ([]configset.Object, error) {
+	fnOpt := fileNameFlags.ToOptions()
+	if err := fnOpt.RequireFilenameOrKustomize(); err != nil {
+		return nil, err
+	}
+	builder := resource.NewLocalBuilder().
+		Unstructured().
+		Flatten().
+		FilenameParam(false, &fnOpt)
+
+	result := builder.Do()
+	infos, err := result.Infos()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource infos: %v", err)
+	}
+	objs := make([]configset.Object, 0, len(infos))
+	for _, info := range infos {
+		objs = append(objs, info.Object.(*unstructured.Unstructured))
+	}
+	return objs, nil
+}
+
+// currentContext returns the name of the kubeconfig context in use.
+func currentContext(configFlags *genericclioptions.ConfigFlags) string {
+	if configFlags.Context != nil && *configFlags.Context != "" {
+		return *configFlags.Context
+	}
+	rawConfig, err := configFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	return rawConfig.CurrentContext
+}
+
+const (
+	storeTypeSecret    = "secret"
+	storeTypeConfigMap = "configmap"
+	storeTypeCRD       = "crd"
+	storeTypeFile      = "file:"
 )
 
-func envOrDefault(env, def string) string {
-	if v := os.Getenv(env); v != "" {
-		return v
+type StoreFlags struct {
+	Type      *string
+	Namespace *string
+}
+
+func NewStoreFlags() *StoreFlags {
+	return &StoreFlags{
+		Type:      func(s string) *string { return &s }(storeTypeSecret),
+		Namespace: func(s string) *string { return &s }(""),
 	}
-	return def
 }
 
-const defaultDiffProgram = "diff -N -u"
+func (f *StoreFlags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(f.Type, "store", *f.Type, "The kind of store to keep config set info in. One of: "+storeTypeSecret+", "+storeTypeConfigMap+", "+storeTypeCRD+", "+storeTypeFile+"<dir>.")
+	flags.StringVar(f.Namespace, "store-namespace", *f.Namespace, "The namespace to store config set info in. Defaults to the target namespace. Set names are unique within the store namespace.")
+}
 
-func diffProgram() string {
-	return envOrDefault("KUBECTL_EXTERNAL_DIFF", defaultDiffProgram)
+// ToStore creates the set info store, with namespace being the target
+// namespace used when no store namespace is specified.
+func (f *StoreFlags) ToStore(kubeClient crclient.Client, namespace string) (configset.SetInfoStore, error) {
+	return newStore(*f.Type, kubeClient, f.storeNamespace(namespace))
+}
+
+func (f *StoreFlags) storeNamespace(namespace string) string {
+	if *f.Namespace != "" {
+		return *f.Namespace
+	}
+	return namespace
+}
+
+func newStore(storeType string, kubeClient crclient.Client, namespace string) (configset.SetInfoStore, error) {
+	var store configset.SetInfoStore
+	var err error
+	switch {
+	case storeType == storeTypeSecret:
+		store, err = configset.NewSecretSetInfoStore(kubeClient, namespace)
+	case storeType == storeTypeConfigMap:
+		store, err = configset.NewConfigMapSetInfoStore(kubeClient, namespace)
+	case storeType == storeTypeCRD:
+		store, err = configset.NewCRDSetInfoStore(kubeClient, namespace)
+	case strings.HasPrefix(storeType, storeTypeFile):
+		store, err = configset.NewFileSetInfoStore(strings.TrimPrefix(storeType, storeTypeFile))
+	default:
+		return nil, fmt.Errorf("unknown store %q", storeType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+	return store, nil
 }
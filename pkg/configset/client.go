@@ -3,9 +3,11 @@ package configset
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -14,7 +16,8 @@ import (
 )
 
 const (
-	DefaultFieldOwner = "configset"
+	DefaultFieldOwner          = "configset"
+	DefaultCRDEstablishTimeout = time.Minute
 )
 
 type Object interface {
@@ -65,10 +68,17 @@ var (
 // apply
 
 type ApplyOptions struct {
+	Namespace           string
+	EnforceNamespace    bool
 	DryRun              bool
 	ForceConflicts      bool
 	PopulateLiveObjects bool
+	CRDEstablishTimeout time.Duration
 	LogObjectResultFunc func(ObjectResult)
+	// Labels and Description of the set are kept as is if nil or empty.
+	Labels      map[string]string
+	Description string
+	Provenance  *Provenance
 }
 
 type ApplyResult struct {
@@ -82,9 +92,14 @@ func (c *Client) Apply(ctx context.Context, name string, objs []Object, opt Appl
 		opt.LogObjectResultFunc = func(or ObjectResult) {}
 	}
 
+	if err := c.resolveNamespaces(objs, opt.Namespace, opt.EnforceNamespace); err != nil {
+		return res, err
+	}
+
 	updatedSetInfo := &SetInfo{
-		Name:      name,
-		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		SchemaVersion: SetInfoSchemaVersion,
+		Name:          name,
+		UpdatedAt:     time.Now().UTC().Format(time.RFC3339),
 	}
 	updatedUIDs := map[string]struct{}{}
 	patchOpts := []crclient.PatchOption{crclient.FieldOwner(c.fieldOwner)}
@@ -95,54 +110,81 @@ func (c *Client) Apply(ctx context.Context, name string, objs []Object, opt Appl
 		patchOpts = append(patchOpts, crclient.ForceOwnership)
 	}
 	hasErrors := false
-	for _, obj := range objs {
-		objRes := ObjectResult{
-			Action: ObjectActionUpdate,
-			Config: obj.DeepCopyObject().(Object),
-		}
+	// custom resource definitions are applied first so that custom resources
+	// of the same set can be applied once their kinds are served
+	crds, others := splitCustomResourceDefinitions(objs)
+	applyObjects := func(objs []Object) {
+		for _, obj := range objs {
+			objRes := ObjectResult{
+				Action: ObjectActionUpdate,
+				Config: obj.DeepCopyObject().(Object),
+			}
 
-		if opt.PopulateLiveObjects {
-			var liveObj unstructured.Unstructured
-			liveObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
-			err := c.kube.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, &liveObj)
-			if apierrors.IsNotFound(err) {
-				objRes.Live = nil
-			} else if err != nil {
+			if opt.PopulateLiveObjects {
+				var liveObj unstructured.Unstructured
+				liveObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+				err := c.kube.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, &liveObj)
+				if apierrors.IsNotFound(err) {
+					objRes.Live = nil
+				} else if err != nil {
+					hasErrors = true
+					objRes.Error = fmt.Errorf("failed to get live object: %w", err)
+					res.ObjectResults = append(res.ObjectResults, objRes)
+					opt.LogObjectResultFunc(objRes)
+					continue
+				} else {
+					objRes.Live = &liveObj
+				}
+			}
+
+			if err := c.kube.Patch(ctx, obj, crclient.Apply, patchOpts...); err != nil {
 				hasErrors = true
-				objRes.Error = fmt.Errorf("failed to get live object: %w", err)
+				objRes.Error = fmt.Errorf("failed to apply object: %w", err)
 				res.ObjectResults = append(res.ObjectResults, objRes)
 				opt.LogObjectResultFunc(objRes)
 				continue
-			} else {
-				objRes.Live = &liveObj
 			}
-		}
-
-		if err := c.kube.Patch(ctx, obj, crclient.Apply, patchOpts...); err != nil {
-			hasErrors = true
-			objRes.Error = fmt.Errorf("failed to apply object: %w", err)
+			objRes.Updated = obj
 			res.ObjectResults = append(res.ObjectResults, objRes)
+
+			gvk := obj.GetObjectKind().GroupVersionKind()
+			apiVersion := gvk.Group + "/" + gvk.Version
+			if gvk.Group == "" {
+				apiVersion = gvk.Version
+			}
+			updatedSetInfo.Resources = append(updatedSetInfo.Resources, ResourceInfo{
+				APIVersion: apiVersion,
+				Kind:       gvk.Kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+				UID:        string(obj.GetUID()),
+			})
+			updatedUIDs[string(obj.GetUID())] = struct{}{}
 			opt.LogObjectResultFunc(objRes)
-			continue
 		}
-		objRes.Updated = obj
-		res.ObjectResults = append(res.ObjectResults, objRes)
-
-		gvk := obj.GetObjectKind().GroupVersionKind()
-		apiVersion := gvk.Group + "/" + gvk.Version
-		if gvk.Group == "" {
-			apiVersion = gvk.Version
+	}
+	applyObjects(crds)
+	if len(crds) > 0 && !opt.DryRun && !hasErrors {
+		timeout := opt.CRDEstablishTimeout
+		if timeout == 0 {
+			timeout = DefaultCRDEstablishTimeout
+		}
+		if err := c.waitForCustomResourceDefinitions(ctx, crds, timeout); err != nil {
+			// skip the remaining objects as their kinds may not be served
+			hasErrors = true
+			for _, obj := range others {
+				objRes := ObjectResult{
+					Action: ObjectActionUpdate,
+					Error:  err,
+					Config: obj.DeepCopyObject().(Object),
+				}
+				res.ObjectResults = append(res.ObjectResults, objRes)
+				opt.LogObjectResultFunc(objRes)
+			}
+			others = nil
 		}
-		updatedSetInfo.Resources = append(updatedSetInfo.Resources, ResourceInfo{
-			APIVersion: apiVersion,
-			Kind:       gvk.Kind,
-			Namespace:  obj.GetNamespace(),
-			Name:       obj.GetName(),
-			UID:        string(obj.GetUID()),
-		})
-		updatedUIDs[string(obj.GetUID())] = struct{}{}
-		opt.LogObjectResultFunc(objRes)
 	}
+	applyObjects(others)
 
 	liveSetInfo, err := c.store.GetSetInfo(ctx, name)
 	if err != nil {
@@ -152,6 +194,16 @@ func (c *Client) Apply(ctx context.Context, name string, objs []Object, opt Appl
 		liveSetInfo = &SetInfo{Name: name}
 	}
 
+	updatedSetInfo.Labels = liveSetInfo.Labels
+	if opt.Labels != nil {
+		updatedSetInfo.Labels = opt.Labels
+	}
+	updatedSetInfo.Description = liveSetInfo.Description
+	if opt.Description != "" {
+		updatedSetInfo.Description = opt.Description
+	}
+	updatedSetInfo.Provenance = opt.Provenance
+
 	// prune resources
 	updatedSetInfoWithLiveMerged := *updatedSetInfo
 	toPrune := []ResourceInfo{}
@@ -231,6 +283,15 @@ func (c *Client) Apply(ctx context.Context, name string, objs []Object, opt Appl
 		if err := c.store.UpdateSetInfo(ctx, name, updatedSetInfo); err != nil {
 			return res, fmt.Errorf("failed to update set info: %w", err)
 		}
+		if recorder, ok := c.store.(ApplyStatusRecorder); ok {
+			status := ApplyStatus{Succeeded: !hasErrors}
+			if hasErrors {
+				status.Message = ErrFailedToOperateSomeResources.Error()
+			}
+			if err := recorder.RecordApplyStatus(ctx, name, status); err != nil {
+				return res, fmt.Errorf("failed to record apply status: %w", err)
+			}
+		}
 	}
 
 	if hasErrors {
@@ -240,6 +301,44 @@ func (c *Client) Apply(ctx context.Context, name string, objs []Object, opt Appl
 	return res, nil
 }
 
+// resolveNamespaces looks up the scope of each object with the RESTMapper, or
+// from the CRDs within objs for kinds that are not served yet. Cluster-scoped
+// objects get their namespace cleared, namespaced objects without one get the
+// given namespace, and if enforceNamespace is set, namespaced objects in any
+// other namespace are rejected.
+func (c *Client) resolveNamespaces(objs []Object, namespace string, enforceNamespace bool) error {
+	mapper := c.kube.RESTMapper()
+	crds, _ := splitCustomResourceDefinitions(objs)
+	crdScopes, err := customResourceScopes(crds)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		var scope meta.RESTScopeName
+		if mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			scope = mapping.Scope.Name()
+		} else if s, ok := crdScopes[gvk.GroupKind()]; ok && meta.IsNoMatchError(err) {
+			// the kind is not served yet but is defined by a CRD of the set
+			scope = s
+		} else {
+			return fmt.Errorf("failed to get rest mapping for %s: %w", gvk, err)
+		}
+		if scope != meta.RESTScopeNameNamespace {
+			obj.SetNamespace("")
+			continue
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+			continue
+		}
+		if enforceNamespace && obj.GetNamespace() != namespace {
+			return fmt.Errorf("the namespace %q of %s %q does not match the namespace %q", obj.GetNamespace(), gvk.Kind, obj.GetName(), namespace)
+		}
+	}
+	return nil
+}
+
 // delete
 
 type DeleteOptions struct {
@@ -255,6 +354,10 @@ type DeleteResult struct {
 func (c *Client) Delete(ctx context.Context, name string, opt DeleteOptions) (DeleteResult, error) {
 	var res DeleteResult
 
+	if opt.LogObjectResultFunc == nil {
+		opt.LogObjectResultFunc = func(or ObjectResult) {}
+	}
+
 	liveSetInfo, err := c.store.GetSetInfo(ctx, name)
 	if err != nil {
 		return res, fmt.Errorf("failed to get set info: %w", err)
@@ -325,3 +428,180 @@ func (c *Client) Delete(ctx context.Context, name string, opt DeleteOptions) (De
 
 	return res, nil
 }
+
+// rename
+
+type RenameOptions struct {
+	DryRun bool
+}
+
+// Rename moves the set info of a set to a new name, along with the state kept
+// by stores implementing SetInfoRenamer. Live objects are left untouched: no
+// labels of the set are written on them, and they are applied with the field
+// manager of the client whatever their set, so there is nothing to rewrite.
+func (c *Client) Rename(ctx context.Context, oldName string, newName string, opt RenameOptions) error {
+	info, err := c.store.GetSetInfo(ctx, oldName)
+	if err != nil {
+		return fmt.Errorf("failed to get set info: %w", err)
+	}
+	if info == nil {
+		return fmt.Errorf("config set %q not found", oldName)
+	}
+	existing, err := c.store.GetSetInfo(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get set info: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("config set %q already exists", newName)
+	}
+	if opt.DryRun {
+		return nil
+	}
+
+	if renamer, ok := c.store.(SetInfoRenamer); ok {
+		return renamer.RenameSetInfo(ctx, oldName, newName)
+	}
+	info.Name = newName
+	if err := c.store.CreateSetInfo(ctx, newName, info); err != nil {
+		return fmt.Errorf("failed to create set info: %w", err)
+	}
+	if err := c.store.DeleteSetInfo(ctx, oldName); err != nil {
+		return &LeftoverSetInfoError{Name: oldName, Err: err}
+	}
+	return nil
+}
+
+// LeftoverSetInfoError is returned when a set info was copied but the
+// original one couldn't be deleted, leaving both in the store.
+type LeftoverSetInfoError struct {
+	Name string
+	Err  error
+}
+
+func (e *LeftoverSetInfoError) Error() string {
+	return fmt.Sprintf("failed to delete set info %s after copying it, it is left over and must be deleted: %v", e.Name, e.Err)
+}
+
+func (e *LeftoverSetInfoError) Unwrap() error {
+	return e.Err
+}
+
+// move
+
+// ResourceRef selects resources of a set. Kind is matched case-insensitively,
+// and empty Group and Namespace match any.
+type ResourceRef struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (r ResourceRef) String() string {
+	kind := strings.ToLower(r.Kind)
+	if r.Group != "" {
+		kind = kind + "." + r.Group
+	}
+	return kind + "/" + r.Name
+}
+
+func (r ResourceRef) matches(info ResourceInfo) bool {
+	ref := refOf(info)
+	return strings.EqualFold(r.Kind, ref.Kind) &&
+		r.Name == ref.Name &&
+		(r.Group == "" || r.Group == ref.Group) &&
+		(r.Namespace == "" || r.Namespace == ref.Namespace)
+}
+
+func refOf(info ResourceInfo) ResourceRef {
+	group := ""
+	if i := strings.LastIndex(info.APIVersion, "/"); i >= 0 {
+		group = info.APIVersion[:i]
+	}
+	return ResourceRef{Group: group, Kind: info.Kind, Namespace: info.Namespace, Name: info.Name}
+}
+
+type MoveOptions struct {
+	DryRun bool
+}
+
+// Move transfers the resources selected by refs from one set to another,
+// creating the destination set if needed, without touching live objects. Each
+// ref must select exactly one resource of the source set that the destination
+// set doesn't have yet. The resources are added to the destination set before
+// being removed from the source one, so that an interrupted move leaves them
+// in both sets rather than in none.
+func (c *Client) Move(ctx context.Context, from string, to string, refs []ResourceRef, opt MoveOptions) ([]ResourceInfo, error) {
+	if from == to {
+		return nil, fmt.Errorf("source and destination sets are the same")
+	}
+	fromInfo, err := c.store.GetSetInfo(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get set info: %w", err)
+	}
+	if fromInfo == nil {
+		return nil, fmt.Errorf("config set %q not found", from)
+	}
+	toInfo, err := c.store.GetSetInfo(ctx, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get set info: %w", err)
+	}
+	if toInfo == nil {
+		toInfo = &SetInfo{SchemaVersion: SetInfoSchemaVersion, Name: to}
+	}
+
+	toUIDs := map[string]struct{}{}
+	for _, r := range toInfo.Resources {
+		toUIDs[r.UID] = struct{}{}
+	}
+	moving := map[int]struct{}{}
+	for _, ref := range refs {
+		matched := -1
+		for i, r := range fromInfo.Resources {
+			if !ref.matches(r) {
+				continue
+			}
+			if matched >= 0 {
+				return nil, fmt.Errorf("%s matches more than one resource of config set %q, specify the group or namespace", ref, from)
+			}
+			matched = i
+		}
+		if matched < 0 {
+			return nil, fmt.Errorf("%s not found in config set %q", ref, from)
+		}
+		if _, ok := toUIDs[fromInfo.Resources[matched].UID]; ok {
+			return nil, fmt.Errorf("%s already exists in config set %q", ref, to)
+		}
+		moving[matched] = struct{}{}
+	}
+
+	var moved []ResourceInfo
+	remaining := []ResourceInfo{}
+	for i, r := range fromInfo.Resources {
+		if _, ok := moving[i]; ok {
+			moved = append(moved, r)
+		} else {
+			remaining = append(remaining, r)
+		}
+	}
+	if opt.DryRun {
+		return moved, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	toInfo.Resources = append(toInfo.Resources, moved...)
+	toInfo.UpdatedAt = now
+	if err := c.store.UpdateSetInfo(ctx, to, toInfo); err != nil {
+		return nil, fmt.Errorf("failed to update set info: %w", err)
+	}
+	fromInfo.Resources = remaining
+	fromInfo.UpdatedAt = now
+	if err := c.store.UpdateSetInfo(ctx, from, fromInfo); err != nil {
+		names := make([]string, 0, len(moved))
+		for _, r := range moved {
+			names = append(names, refOf(r).String())
+		}
+		return nil, fmt.Errorf("failed to remove %s from config set %q after adding them to %q, they belong to both: %w", strings.Join(names, ", "), from, to, err)
+	}
+	return moved, nil
+}
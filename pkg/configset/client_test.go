@@ -0,0 +1,380 @@
+package configset_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wxdao/configset/pkg/configset"
+	"github.com/wxdao/configset/pkg/configsettest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// failingStore fails the operations whose functions return an error.
+type failingStore struct {
+	*configsettest.MemorySetInfoStore
+	update func(name string) error
+	delete func(name string) error
+}
+
+func (s *failingStore) UpdateSetInfo(ctx context.Context, name string, info *configset.SetInfo) error {
+	if s.update != nil {
+		if err := s.update(name); err != nil {
+			return err
+		}
+	}
+	return s.MemorySetInfoStore.UpdateSetInfo(ctx, name, info)
+}
+
+func (s *failingStore) DeleteSetInfo(ctx context.Context, name string) error {
+	if s.delete != nil {
+		if err := s.delete(name); err != nil {
+			return err
+		}
+	}
+	return s.MemorySetInfoStore.DeleteSetInfo(ctx, name)
+}
+
+func TestRenameReportsLeftoverSet(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStore{
+		MemorySetInfoStore: configsettest.NewMemorySetInfoStore(),
+		delete: func(name string) error {
+			return errors.New("forbidden")
+		},
+	}
+	if err := store.CreateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	cli, err := configset.NewClient(configsettest.NewFakeKubeClient(configsettest.FakeKubeClientOptions{}), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cli.Rename(ctx, "a", "b", configset.RenameOptions{})
+	var leftover *configset.LeftoverSetInfoError
+	if !errors.As(err, &leftover) || leftover.Name != "a" {
+		t.Fatalf("Rename returned %v, want a LeftoverSetInfoError for a", err)
+	}
+	configsettest.AssertSetInfo(t, store, "a", []configset.ResourceInfo{})
+	configsettest.AssertSetInfo(t, store, "b", []configset.ResourceInfo{})
+}
+
+func TestMoveLeavesDuplicatesOnFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStore{MemorySetInfoStore: configsettest.NewMemorySetInfoStore()}
+	x := configset.ResourceInfo{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "x", UID: "uid-x"}
+	y := configset.ResourceInfo{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "y", UID: "uid-y"}
+	if err := store.CreateSetInfo(ctx, "a", &configset.SetInfo{Name: "a", Resources: []configset.ResourceInfo{x, y}}); err != nil {
+		t.Fatal(err)
+	}
+	store.update = func(name string) error {
+		if name == "a" {
+			return errors.New("forbidden")
+		}
+		return nil
+	}
+	cli, err := configset.NewClient(configsettest.NewFakeKubeClient(configsettest.FakeKubeClientOptions{}), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cli.Move(ctx, "a", "b", []configset.ResourceRef{{Kind: "Deployment", Name: "x"}}, configset.MoveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "deployment.apps/x") {
+		t.Fatalf("Move returned %v, want an error naming deployment.apps/x", err)
+	}
+	configsettest.AssertSetInfo(t, store, "a", []configset.ResourceInfo{x, y})
+	configsettest.AssertSetInfo(t, store, "b", []configset.ResourceInfo{x})
+}
+
+func TestRenameLeavesLiveObjects(t *testing.T) {
+	ctx := context.Background()
+	fake := configsettest.NewFake(configsettest.FakeKubeClientOptions{})
+	configMap := configsettest.NewObject("v1", "ConfigMap", "", "x")
+	configMap.Object["data"] = map[string]interface{}{"k": "v"}
+	if _, err := fake.Client.Apply(ctx, "a", []configset.Object{configMap}, configset.ApplyOptions{Namespace: "default"}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	getLive := func() *unstructured.Unstructured {
+		live := configsettest.NewObject("v1", "ConfigMap", "", "")
+		if err := fake.Kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: "x"}, live); err != nil {
+			t.Fatalf("failed to get config map x: %v", err)
+		}
+		return live
+	}
+	before := getLive()
+
+	if err := fake.Client.Rename(ctx, "a", "b", configset.RenameOptions{DryRun: true}); err != nil {
+		t.Fatalf("Rename with dry run: %v", err)
+	}
+	configsettest.AssertSetInfo(t, fake.Store, "b", nil)
+	if err := fake.Client.Rename(ctx, "a", "b", configset.RenameOptions{}); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	configsettest.AssertSetInfo(t, fake.Store, "a", nil)
+	resource := configset.ResourceInfo{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "x", UID: string(before.GetUID())}
+	configsettest.AssertSetInfo(t, fake.Store, "b", []configset.ResourceInfo{resource})
+
+	// the object is neither rewritten nor recreated
+	if after := getLive(); !reflect.DeepEqual(after.Object, before.Object) {
+		t.Errorf("config map x changed by the rename:\n%v\nwant\n%v", after.Object, before.Object)
+	}
+
+	// and applying the renamed set takes it over without pruning it
+	res, err := fake.Client.Apply(ctx, "b", []configset.Object{configMap}, configset.ApplyOptions{Namespace: "default"})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	configsettest.AssertApplyResult(t, res,
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+	)
+	if after := getLive(); after.GetUID() != before.GetUID() {
+		t.Errorf("config map x was recreated by applying the renamed set")
+	}
+}
+
+// newCRD returns a CustomResourceDefinition of kind in the example.com group.
+func newCRD(kind string, scope string) *unstructured.Unstructured {
+	plural := strings.ToLower(kind) + "s"
+	crd := configsettest.NewObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", plural+".example.com")
+	crd.Object["spec"] = map[string]interface{}{
+		"group": "example.com",
+		"scope": scope,
+		"names": map[string]interface{}{"kind": kind, "plural": plural},
+		"versions": []interface{}{
+			map[string]interface{}{"name": "v1", "served": true, "storage": true},
+		},
+	}
+	return crd
+}
+
+func TestApplyResolvesNamespaces(t *testing.T) {
+	tests := []struct {
+		name      string
+		objs      []configset.Object
+		enforce   bool
+		dryRun    bool
+		want      []configsettest.ExpectedObjectResult
+		wantError string
+	}{
+		{
+			name: "cluster-scoped",
+			objs: []configset.Object{
+				configsettest.NewObject("rbac.authorization.k8s.io/v1", "ClusterRole", "default", "reader"),
+				configsettest.NewObject("storage.k8s.io/v1", "StorageClass", "", "fast"),
+			},
+			want: []configsettest.ExpectedObjectResult{
+				{Action: configset.ObjectActionUpdate, Kind: "ClusterRole", Name: "reader"},
+				{Action: configset.ObjectActionUpdate, Kind: "StorageClass", Name: "fast"},
+			},
+		},
+		{
+			name: "namespaced",
+			objs: []configset.Object{
+				configsettest.NewObject("v1", "ConfigMap", "", "x"),
+				configsettest.NewObject("v1", "ConfigMap", "other", "y"),
+			},
+			want: []configsettest.ExpectedObjectResult{
+				{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+				{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "other", Name: "y"},
+			},
+		},
+		{
+			name: "enforced namespace",
+			objs: []configset.Object{
+				configsettest.NewObject("v1", "ConfigMap", "", "x"),
+				configsettest.NewObject("v1", "ConfigMap", "default", "y"),
+				configsettest.NewObject("rbac.authorization.k8s.io/v1", "ClusterRole", "other", "reader"),
+			},
+			enforce: true,
+			want: []configsettest.ExpectedObjectResult{
+				{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+				{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "y"},
+				{Action: configset.ObjectActionUpdate, Kind: "ClusterRole", Name: "reader"},
+			},
+		},
+		{
+			name: "other namespace than the enforced one",
+			objs: []configset.Object{
+				configsettest.NewObject("v1", "ConfigMap", "", "x"),
+				configsettest.NewObject("v1", "ConfigMap", "other", "y"),
+			},
+			enforce:   true,
+			wantError: `the namespace "other" of ConfigMap "y" does not match the namespace "default"`,
+		},
+		{
+			name: "unknown kind",
+			objs: []configset.Object{
+				configsettest.NewObject("example.com/v1", "Widget", "", "w"),
+			},
+			wantError: "failed to get rest mapping",
+		},
+		{
+			name: "custom resources of the set",
+			objs: []configset.Object{
+				configsettest.NewObject("example.com/v1", "Widget", "", "w"),
+				configsettest.NewObject("example.com/v1", "ClusterWidget", "default", "cw"),
+				newCRD("Widget", "Namespaced"),
+				newCRD("ClusterWidget", "Cluster"),
+			},
+			// the fake cluster never establishes the CRDs
+			dryRun: true,
+			want: []configsettest.ExpectedObjectResult{
+				{Action: configset.ObjectActionUpdate, Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+				{Action: configset.ObjectActionUpdate, Kind: "CustomResourceDefinition", Name: "clusterwidgets.example.com"},
+				{Action: configset.ObjectActionUpdate, Kind: "Widget", Namespace: "default", Name: "w"},
+				{Action: configset.ObjectActionUpdate, Kind: "ClusterWidget", Name: "cw"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := configsettest.NewFake(configsettest.FakeKubeClientOptions{})
+			res, err := fake.Client.Apply(context.Background(), "a", tt.objs, configset.ApplyOptions{
+				Namespace:        "default",
+				EnforceNamespace: tt.enforce,
+				DryRun:           tt.dryRun,
+			})
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("Apply returned %v, want %q", err, tt.wantError)
+				}
+				configsettest.AssertSetInfo(t, fake.Store, "a", nil)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			configsettest.AssertApplyResult(t, res, tt.want...)
+			if tt.dryRun {
+				return
+			}
+
+			// the set info records the scope of each resource
+			var resources []configset.ResourceInfo
+			for _, r := range res.ObjectResults {
+				gvk := r.Config.GetObjectKind().GroupVersionKind()
+				apiVersion, kind := gvk.ToAPIVersionAndKind()
+				resources = append(resources, configset.ResourceInfo{APIVersion: apiVersion, Kind: kind, Namespace: r.Config.GetNamespace(), Name: r.Config.GetName()})
+			}
+			configsettest.AssertSetInfo(t, fake.Store, "a", resources)
+		})
+	}
+}
+
+func TestApplyCustomResourceDefinitionsFirst(t *testing.T) {
+	ctx := context.Background()
+	// the kinds of the CRDs are added to customMapper once established
+	customMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "example.com", Version: "v1"}})
+	mapper := meta.MultiRESTMapper{configsettest.NewRESTMapper(clientgoscheme.Scheme), customMapper}
+	fake := configsettest.NewFake(configsettest.FakeKubeClientOptions{RESTMapper: mapper})
+	objs := []configset.Object{
+		configsettest.NewObject("example.com/v1", "Widget", "", "w"),
+		configsettest.NewObject("example.com/v1", "ClusterWidget", "default", "cw"),
+		newCRD("Widget", "Namespaced"),
+		configsettest.NewObject("v1", "ConfigMap", "", "x"),
+		newCRD("ClusterWidget", "Cluster"),
+	}
+
+	var applied []string
+	res, err := fake.Client.Apply(ctx, "a", objs, configset.ApplyOptions{
+		Namespace: "default",
+		LogObjectResultFunc: func(r configset.ObjectResult) {
+			gvk := r.Config.GetObjectKind().GroupVersionKind()
+			applied = append(applied, gvk.Kind+"/"+r.Config.GetName())
+			if gvk.Kind != "CustomResourceDefinition" || r.Error != nil {
+				return
+			}
+			// establish the CRD as the apiserver would, serving its kind
+			crd := configsettest.NewObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "")
+			if err := fake.Kube.Get(ctx, types.NamespacedName{Name: r.Config.GetName()}, crd); err != nil {
+				t.Fatalf("failed to get crd: %v", err)
+			}
+			crd.Object["status"] = map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{"type": "Established", "status": "True"}},
+			}
+			if err := fake.Kube.Update(ctx, crd); err != nil {
+				t.Fatalf("failed to update crd: %v", err)
+			}
+			kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+			scope := meta.RESTScopeRoot
+			if s, _, _ := unstructured.NestedString(crd.Object, "spec", "scope"); s == "Namespaced" {
+				scope = meta.RESTScopeNamespace
+			}
+			customMapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: kind}, scope)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := []string{
+		"CustomResourceDefinition/widgets.example.com",
+		"CustomResourceDefinition/clusterwidgets.example.com",
+		"Widget/w",
+		"ClusterWidget/cw",
+		"ConfigMap/x",
+	}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied %v, want %v", applied, want)
+	}
+	configsettest.AssertApplyResult(t, res,
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "CustomResourceDefinition", Name: "clusterwidgets.example.com"},
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "Widget", Namespace: "default", Name: "w"},
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ClusterWidget", Name: "cw"},
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+	)
+	widget := configsettest.NewObject("example.com/v1", "Widget", "", "")
+	if err := fake.Kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: "w"}, widget); err != nil {
+		t.Errorf("failed to get widget w: %v", err)
+	}
+}
+
+// forbiddenCRDClient forbids getting CRDs.
+type forbiddenCRDClient struct {
+	crclient.Client
+}
+
+func (c *forbiddenCRDClient) Get(ctx context.Context, key crclient.ObjectKey, obj crclient.Object) error {
+	if obj.GetObjectKind().GroupVersionKind().Kind == "CustomResourceDefinition" {
+		return apierrors.NewForbidden(schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}, key.Name, errors.New("not allowed"))
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestApplyFailsWaitingForForbiddenCRDs(t *testing.T) {
+	kube := &forbiddenCRDClient{Client: configsettest.NewFakeKubeClient(configsettest.FakeKubeClientOptions{})}
+	cli, err := configset.NewClient(kube, configsettest.NewMemorySetInfoStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	res, err := cli.Apply(context.Background(), "a", []configset.Object{
+		newCRD("Widget", "Namespaced"),
+		configsettest.NewObject("example.com/v1", "Widget", "", "w"),
+	}, configset.ApplyOptions{Namespace: "default", CRDEstablishTimeout: time.Minute})
+	if !errors.Is(err, configset.ErrFailedToOperateSomeResources) {
+		t.Fatalf("Apply returned %v, want %v", err, configset.ErrFailedToOperateSomeResources)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Apply took %v, want it to fail right away", elapsed)
+	}
+	configsettest.AssertApplyResult(t, res,
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+		configsettest.ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "Widget", Namespace: "default", Name: "w", Error: true},
+	)
+	if err := res.ObjectResults[1].Error; err == nil || !apierrors.IsForbidden(err) {
+		t.Errorf("widget w failed with %v, want the forbidden error", err)
+	}
+}
@@ -0,0 +1,148 @@
+package configset
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultSetInfoConfigMapPrefix            = DefaultSetInfoSecretPrefix
+	DefaultSetInfoConfigMapDataKey           = DefaultSetInfoSecretDataKey
+	DefaultSetInfoConfigMapFieldOwner        = "configset/configmap-store"
+	DefaultSetInfoConfigMapLockAnnotationKey = DefaultSetInfoSecretLockAnnotationKey
+	DefaultSetInfoConfigMapIsSetInfoLabelKey = DefaultSetInfoSecretIsSetInfoLabelKey
+)
+
+type ConfigMapSetInfoStore struct {
+	kube              crclient.Client
+	namespace         string
+	namePrefix        string
+	dataKey           string
+	fieldOwner        string
+	lockAnnoKey       string
+	isSetInfoLabelKey string
+}
+
+var _ SetInfoStore = &ConfigMapSetInfoStore{}
+var _ AllNamespacesSetInfoLister = &ConfigMapSetInfoStore{}
+
+func NewConfigMapSetInfoStore(kubeClient crclient.Client, namespace string) (*ConfigMapSetInfoStore, error) {
+	return &ConfigMapSetInfoStore{
+		kube:              kubeClient,
+		namespace:         namespace,
+		namePrefix:        DefaultSetInfoConfigMapPrefix,
+		dataKey:           DefaultSetInfoConfigMapDataKey,
+		fieldOwner:        DefaultSetInfoConfigMapFieldOwner,
+		lockAnnoKey:       DefaultSetInfoConfigMapLockAnnotationKey,
+		isSetInfoLabelKey: DefaultSetInfoConfigMapIsSetInfoLabelKey,
+	}, nil
+}
+
+func (s *ConfigMapSetInfoStore) GetSetInfo(ctx context.Context, name string) (*SetInfo, error) {
+	var cm corev1.ConfigMap
+	if err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.namePrefix + name}, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get configmap: %w", err)
+	}
+	return setInfoFromJSON([]byte(cm.Data[s.dataKey]))
+}
+
+func (s *ConfigMapSetInfoStore) ListSetInfos(ctx context.Context) ([]*SetInfo, error) {
+	var cmList corev1.ConfigMapList
+	if err := s.kube.List(ctx, &cmList, crclient.InNamespace(s.namespace), crclient.HasLabels{s.isSetInfoLabelKey}); err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+	infos := make([]*SetInfo, 0, len(cmList.Items))
+	for _, cm := range cmList.Items {
+		info, err := setInfoFromJSON([]byte(cm.Data[s.dataKey]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse configmap %s: %w", cm.Name, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *ConfigMapSetInfoStore) ListAllNamespacesSetInfos(ctx context.Context) ([]NamespacedSetInfo, []string, error) {
+	var cms []corev1.ConfigMap
+	skipped, err := listInAllNamespaces(ctx, s.kube, "configmaps", func(namespace string) error {
+		var cmList corev1.ConfigMapList
+		if err := s.kube.List(ctx, &cmList, crclient.InNamespace(namespace), crclient.HasLabels{s.isSetInfoLabelKey}); err != nil {
+			return err
+		}
+		cms = append(cms, cmList.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	infos := make([]NamespacedSetInfo, 0, len(cms))
+	for _, cm := range cms {
+		info, err := setInfoFromJSON([]byte(cm.Data[s.dataKey]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse configmap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+		infos = append(infos, NamespacedSetInfo{Namespace: cm.Namespace, SetInfo: info})
+	}
+	return infos, skipped, nil
+}
+
+func (s *ConfigMapSetInfoStore) CreateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.namespace,
+			Name:      s.namePrefix + name,
+			Labels: map[string]string{
+				s.isSetInfoLabelKey: "true",
+			},
+		},
+		Data: map[string]string{
+			s.dataKey: string(info.toJSON()),
+		},
+	}
+	if err := s.kube.Create(ctx, cm, crclient.FieldOwner(s.fieldOwner)); err != nil {
+		return fmt.Errorf("failed to create configmap: %w", err)
+	}
+	return nil
+}
+
+func (s *ConfigMapSetInfoStore) UpdateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	cm := corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.namespace,
+			Name:      s.namePrefix + name,
+			Labels: map[string]string{
+				s.isSetInfoLabelKey: "true",
+			},
+		},
+		Data: map[string]string{s.dataKey: string(info.toJSON())},
+	}
+	if err := s.kube.Patch(ctx, &cm, crclient.Apply, crclient.FieldOwner(s.fieldOwner), crclient.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to update configmap: %w", err)
+	}
+	return nil
+}
+
+func (s *ConfigMapSetInfoStore) DeleteSetInfo(ctx context.Context, name string) error {
+	if err := crclient.IgnoreNotFound(s.kube.Delete(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.namespace,
+			Name:      s.namePrefix + name,
+		},
+	})); err != nil {
+		return fmt.Errorf("failed to delete configmap: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package configset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+const crdPollInterval = time.Second
+
+// splitCustomResourceDefinitions separates CRDs from other objects, keeping the
+// original order within each group.
+func splitCustomResourceDefinitions(objs []Object) (crds []Object, others []Object) {
+	for _, obj := range objs {
+		if obj.GetObjectKind().GroupVersionKind().GroupKind() == crdGroupKind {
+			crds = append(crds, obj)
+		} else {
+			others = append(others, obj)
+		}
+	}
+	return crds, others
+}
+
+// customResourceScopes returns the scope of the kinds defined by crds.
+func customResourceScopes(crds []Object) (map[schema.GroupKind]meta.RESTScopeName, error) {
+	scopes := map[schema.GroupKind]meta.RESTScopeName{}
+	for _, crd := range crds {
+		gk, scope, err := customResourceGroupKind(crd)
+		if err != nil {
+			return nil, err
+		}
+		if scope == "Namespaced" {
+			scopes[gk] = meta.RESTScopeNameNamespace
+		} else {
+			scopes[gk] = meta.RESTScopeNameRoot
+		}
+	}
+	return scopes, nil
+}
+
+func customResourceGroupKind(crd Object) (schema.GroupKind, string, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+	if err != nil {
+		return schema.GroupKind{}, "", fmt.Errorf("failed to convert crd %s: %w", crd.GetName(), err)
+	}
+	group, _, _ := unstructured.NestedString(content, "spec", "group")
+	kind, _, _ := unstructured.NestedString(content, "spec", "names", "kind")
+	scope, _, _ := unstructured.NestedString(content, "spec", "scope")
+	return schema.GroupKind{Group: group, Kind: kind}, scope, nil
+}
+
+// waitForCustomResourceDefinitions waits until crds are established and their
+// kinds can be mapped by the client's RESTMapper, failing right away on errors
+// getting them other than not found.
+func (c *Client) waitForCustomResourceDefinitions(ctx context.Context, crds []Object, timeout time.Duration) error {
+	mapper := c.kube.RESTMapper()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, crd := range crds {
+		gk, _, err := customResourceGroupKind(crd)
+		if err != nil {
+			return err
+		}
+		err = wait.PollImmediateUntil(crdPollInterval, func() (bool, error) {
+			var live unstructured.Unstructured
+			live.SetGroupVersionKind(crd.GetObjectKind().GroupVersionKind())
+			if err := c.kube.Get(ctx, types.NamespacedName{Name: crd.GetName()}, &live); err != nil {
+				if apierrors.IsNotFound(err) {
+					return false, nil
+				}
+				// e.g. forbidden or canceled, which waiting doesn't fix
+				return false, err
+			}
+			if !isCustomResourceDefinitionEstablished(&live) {
+				return false, nil
+			}
+			if r, ok := mapper.(meta.ResettableRESTMapper); ok {
+				r.Reset()
+			}
+			_, err := mapper.RESTMapping(gk)
+			return err == nil, nil
+		}, ctx.Done())
+		if err != nil {
+			return fmt.Errorf("failed to wait for crd %s to be established: %w", crd.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func isCustomResourceDefinitionEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == "Established" && cond["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package configset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultSetInfoCRDFieldOwner = "configset/crd-store"
+
+	ConfigSetHealthHealthy  = "Healthy"
+	ConfigSetHealthDegraded = "Degraded"
+
+	ConfigSetApplyOutcomeSucceeded = "Succeeded"
+	ConfigSetApplyOutcomeFailed    = "Failed"
+)
+
+var (
+	ConfigSetGroupVersionKind     = schema.GroupVersionKind{Group: "configset.wxdao.io", Version: "v1alpha1", Kind: "ConfigSet"}
+	ConfigSetListGroupVersionKind = schema.GroupVersionKind{Group: "configset.wxdao.io", Version: "v1alpha1", Kind: "ConfigSetList"}
+)
+
+// CRDSetInfoStore stores set infos as ConfigSet custom resources, which need
+// to be installed from config/crd beforehand. The resources of a set are kept
+// in spec, while the revision and the outcome of the last apply are reported
+// in status.
+type CRDSetInfoStore struct {
+	kube       crclient.Client
+	namespace  string
+	fieldOwner string
+}
+
+var _ SetInfoStore = &CRDSetInfoStore{}
+var _ AllNamespacesSetInfoLister = &CRDSetInfoStore{}
+var _ ApplyStatusRecorder = &CRDSetInfoStore{}
+var _ SetInfoRenamer = &CRDSetInfoStore{}
+
+func NewCRDSetInfoStore(kubeClient crclient.Client, namespace string) (*CRDSetInfoStore, error) {
+	return &CRDSetInfoStore{
+		kube:       kubeClient,
+		namespace:  namespace,
+		fieldOwner: DefaultSetInfoCRDFieldOwner,
+	}, nil
+}
+
+func (s *CRDSetInfoStore) GetSetInfo(ctx context.Context, name string) (*SetInfo, error) {
+	var obj unstructured.Unstructured
+	obj.SetGroupVersionKind(ConfigSetGroupVersionKind)
+	if err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: name}, &obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get configset: %w", err)
+	}
+	return setInfoFromConfigSet(&obj)
+}
+
+func (s *CRDSetInfoStore) ListSetInfos(ctx context.Context) ([]*SetInfo, error) {
+	var list unstructured.UnstructuredList
+	list.SetGroupVersionKind(ConfigSetListGroupVersionKind)
+	if err := s.kube.List(ctx, &list, crclient.InNamespace(s.namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list configsets: %w", err)
+	}
+	infos := make([]*SetInfo, 0, len(list.Items))
+	for i := range list.Items {
+		info, err := setInfoFromConfigSet(&list.Items[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse configset %s: %w", list.Items[i].GetName(), err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *CRDSetInfoStore) ListAllNamespacesSetInfos(ctx context.Context) ([]NamespacedSetInfo, []string, error) {
+	var objs []unstructured.Unstructured
+	skipped, err := listInAllNamespaces(ctx, s.kube, "configsets", func(namespace string) error {
+		var list unstructured.UnstructuredList
+		list.SetGroupVersionKind(ConfigSetListGroupVersionKind)
+		if err := s.kube.List(ctx, &list, crclient.InNamespace(namespace)); err != nil {
+			return err
+		}
+		objs = append(objs, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	infos := make([]NamespacedSetInfo, 0, len(objs))
+	for i := range objs {
+		obj := &objs[i]
+		info, err := setInfoFromConfigSet(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse configset %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+		infos = append(infos, NamespacedSetInfo{Namespace: obj.GetNamespace(), SetInfo: info})
+	}
+	return infos, skipped, nil
+}
+
+func (s *CRDSetInfoStore) CreateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	obj, err := s.configSetFromSetInfo(name, info)
+	if err != nil {
+		return err
+	}
+	if err := s.kube.Create(ctx, obj, crclient.FieldOwner(s.fieldOwner)); err != nil {
+		return fmt.Errorf("failed to create configset: %w", err)
+	}
+	return s.patchStatus(ctx, obj, map[string]interface{}{
+		"revision":      1,
+		"resourceCount": len(info.Resources),
+	})
+}
+
+func (s *CRDSetInfoStore) UpdateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	obj, err := s.configSetFromSetInfo(name, info)
+	if err != nil {
+		return err
+	}
+	if err := s.kube.Patch(ctx, obj, crclient.Apply, crclient.FieldOwner(s.fieldOwner), crclient.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to update configset: %w", err)
+	}
+	return s.bumpRevision(ctx, obj, len(info.Resources))
+}
+
+// bumpRevision increments the revision in the status of obj, which must be
+// the object as last written, and sets its resource count. The patch is
+// guarded by the resourceVersion of obj so that concurrent updates can't write
+// the same revision, and is retried on the latest object on conflicts.
+func (s *CRDSetInfoStore) bumpRevision(ctx context.Context, obj *unstructured.Unstructured, resourceCount int) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		revision, _, _ := unstructured.NestedInt64(obj.Object, "status", "revision")
+		b, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"resourceVersion": obj.GetResourceVersion()},
+			"status": map[string]interface{}{
+				"revision":      revision + 1,
+				"resourceCount": resourceCount,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.kube.Status().Patch(ctx, obj, crclient.RawPatch(types.MergePatchType, b), crclient.FieldOwner(s.fieldOwner)); err != nil {
+			if apierrors.IsConflict(err) {
+				if err := s.kube.Get(ctx, crclient.ObjectKeyFromObject(obj), obj); err != nil {
+					return fmt.Errorf("failed to get configset: %w", err)
+				}
+			}
+			return fmt.Errorf("failed to update configset status: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *CRDSetInfoStore) DeleteSetInfo(ctx context.Context, name string) error {
+	var obj unstructured.Unstructured
+	obj.SetGroupVersionKind(ConfigSetGroupVersionKind)
+	obj.SetNamespace(s.namespace)
+	obj.SetName(name)
+	if err := crclient.IgnoreNotFound(s.kube.Delete(ctx, &obj)); err != nil {
+		return fmt.Errorf("failed to delete configset: %w", err)
+	}
+	return nil
+}
+
+// RenameSetInfo creates the configset newName with the spec and status of
+// oldName, then deletes oldName.
+func (s *CRDSetInfoStore) RenameSetInfo(ctx context.Context, oldName string, newName string) error {
+	var old unstructured.Unstructured
+	old.SetGroupVersionKind(ConfigSetGroupVersionKind)
+	if err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: oldName}, &old); err != nil {
+		return fmt.Errorf("failed to get configset: %w", err)
+	}
+	info, err := setInfoFromConfigSet(&old)
+	if err != nil {
+		return err
+	}
+	obj, err := s.configSetFromSetInfo(newName, info)
+	if err != nil {
+		return err
+	}
+	if err := s.kube.Create(ctx, obj, crclient.FieldOwner(s.fieldOwner)); err != nil {
+		return fmt.Errorf("failed to create configset: %w", err)
+	}
+	if status, _, _ := unstructured.NestedMap(old.Object, "status"); len(status) > 0 {
+		if err := s.patchStatus(ctx, obj, status); err != nil {
+			return err
+		}
+	}
+	if err := s.DeleteSetInfo(ctx, oldName); err != nil {
+		return &LeftoverSetInfoError{Name: oldName, Err: err}
+	}
+	return nil
+}
+
+func (s *CRDSetInfoStore) RecordApplyStatus(ctx context.Context, name string, status ApplyStatus) error {
+	var obj unstructured.Unstructured
+	obj.SetGroupVersionKind(ConfigSetGroupVersionKind)
+	obj.SetNamespace(s.namespace)
+	obj.SetName(name)
+	outcome, health := ConfigSetApplyOutcomeSucceeded, ConfigSetHealthHealthy
+	if !status.Succeeded {
+		outcome, health = ConfigSetApplyOutcomeFailed, ConfigSetHealthDegraded
+	}
+	return s.patchStatus(ctx, &obj, map[string]interface{}{
+		"lastApplyOutcome": outcome,
+		"health":           health,
+		"message":          status.Message,
+	})
+}
+
+func (s *CRDSetInfoStore) patchStatus(ctx context.Context, obj *unstructured.Unstructured, status map[string]interface{}) error {
+	b, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	if err := s.kube.Status().Patch(ctx, obj, crclient.RawPatch(types.MergePatchType, b), crclient.FieldOwner(s.fieldOwner)); err != nil {
+		return fmt.Errorf("failed to update configset status: %w", err)
+	}
+	return nil
+}
+
+func (s *CRDSetInfoStore) configSetFromSetInfo(name string, info *SetInfo) (*unstructured.Unstructured, error) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(info.toJSON(), &spec); err != nil {
+		return nil, err
+	}
+	delete(spec, "name")
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(ConfigSetGroupVersionKind)
+	obj.SetNamespace(s.namespace)
+	obj.SetName(name)
+	return obj, nil
+}
+
+func setInfoFromConfigSet(obj *unstructured.Unstructured) (*SetInfo, error) {
+	spec, _, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	info, err := setInfoFromJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	info.Name = obj.GetName()
+	return info, nil
+}
@@ -0,0 +1,109 @@
+package configset
+
+import (
+	"github.com/wxdao/configset/pkg/diffutil"
+)
+
+type ObjectDiffStat struct {
+	Action     DiffAction `json:"action"`
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	Added      int        `json:"added"`
+	Removed    int        `json:"removed"`
+}
+
+type DiffStatTotals struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Delete    int `json:"delete"`
+	Unchanged int `json:"unchanged"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+}
+
+type DiffStat struct {
+	Objects []ObjectDiffStat `json:"objects"`
+	Totals  DiffStatTotals   `json:"totals"`
+}
+
+// DiffStatObjectResults summarizes the changes of results, counting the lines
+// added and removed in the YAML of each object the same way as
+// AddObjectResultsToDiffer writes them. Results with errors are skipped.
+func DiffStatObjectResults(results []ObjectResult, opt AddObjectResultsToDifferOptions) (DiffStat, error) {
+	stat := DiffStat{Objects: []ObjectDiffStat{}}
+	diffs, err := diffObjectResultsYAML(results, opt)
+	if err != nil {
+		return stat, err
+	}
+	for _, diff := range diffs {
+		switch diff.stat.Action {
+		case DiffActionCreate:
+			stat.Totals.Create++
+		case DiffActionDelete:
+			stat.Totals.Delete++
+		case DiffActionUnchanged:
+			stat.Totals.Unchanged++
+		default:
+			stat.Totals.Update++
+		}
+		stat.Totals.Added += diff.stat.Added
+		stat.Totals.Removed += diff.stat.Removed
+		stat.Objects = append(stat.Objects, diff.stat)
+	}
+	return stat, nil
+}
+
+// objectResultYAML is the normalized YAML of the live and updated objects of
+// a result, nil if absent.
+type objectResultYAML struct {
+	result  ObjectResult
+	stat    ObjectDiffStat
+	live    []byte
+	updated []byte
+}
+
+func diffObjectResultsYAML(results []ObjectResult, opt AddObjectResultsToDifferOptions) ([]objectResultYAML, error) {
+	var diffs []objectResultYAML
+	for _, result := range results {
+		if result.Error != nil || (result.Live == nil && result.Updated == nil) {
+			continue
+		}
+
+		normalized, err := normalizeObjectResult(result, opt)
+		if err != nil {
+			return nil, err
+		}
+		diff := objectResultYAML{result: result}
+		if diff.live, diff.updated, err = normalized.yaml(); err != nil {
+			return nil, err
+		}
+
+		obj := result.Live
+		if obj == nil {
+			obj = result.Updated
+		}
+		apiVersion, kind := obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+		diff.stat = ObjectDiffStat{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		}
+		diff.stat.Added, diff.stat.Removed = diffutil.LineStat(diff.live, diff.updated)
+
+		switch {
+		case diff.live == nil:
+			diff.stat.Action = DiffActionCreate
+		case diff.updated == nil:
+			diff.stat.Action = DiffActionDelete
+		case diff.stat.Added == 0 && diff.stat.Removed == 0:
+			diff.stat.Action = DiffActionUnchanged
+		default:
+			diff.stat.Action = DiffActionUpdate
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
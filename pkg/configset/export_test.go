@@ -0,0 +1,7 @@
+package configset
+
+// SetChunkSize lowers the chunk size of s so that tests can shard small set
+// infos.
+func (s *SecretSetInfoStore) SetChunkSize(n int) {
+	s.chunkSize = n
+}
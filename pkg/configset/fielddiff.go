@@ -0,0 +1,237 @@
+package configset
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type DiffAction string
+
+const (
+	DiffActionCreate    DiffAction = "create"
+	DiffActionUpdate    DiffAction = "update"
+	DiffActionDelete    DiffAction = "delete"
+	DiffActionUnchanged DiffAction = "unchanged"
+)
+
+// FieldChange is a changed field, Old being nil if the field is added and New
+// being nil if it is removed.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+type ObjectDiff struct {
+	Action     DiffAction    `json:"action"`
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+	// Ownership is set with OwnedFieldsOnly if the field manager starts or
+	// stops owning some fields.
+	Ownership *OwnershipChanges `json:"ownership,omitempty"`
+}
+
+// DiffObjectResults compares the live and updated objects of results field by
+// field, after normalizing them the same way as AddObjectResultsToDiffer.
+// Results with errors are skipped.
+func DiffObjectResults(results []ObjectResult, opt AddObjectResultsToDifferOptions) ([]ObjectDiff, error) {
+	diffs := []ObjectDiff{}
+	for _, result := range results {
+		if result.Error != nil || (result.Live == nil && result.Updated == nil) {
+			continue
+		}
+
+		obj := result.Live
+		if obj == nil {
+			obj = result.Updated
+		}
+		apiVersion, kind := obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+		diff := ObjectDiff{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		}
+
+		switch {
+		case result.Live == nil:
+			diff.Action = DiffActionCreate
+		case result.Updated == nil:
+			diff.Action = DiffActionDelete
+		default:
+			normalized, err := normalizeObjectResult(result, opt)
+			if err != nil {
+				return nil, err
+			}
+			liveContent, err := toJSONValue(normalized.live)
+			if err != nil {
+				return nil, err
+			}
+			updatedContent, err := toJSONValue(normalized.updated)
+			if err != nil {
+				return nil, err
+			}
+			diff.Changes = diffFields("", liveContent, updatedContent, nil)
+			if !normalized.ownership.empty() {
+				diff.Ownership = normalized.ownership
+			}
+			diff.Action = DiffActionUpdate
+			if len(diff.Changes) == 0 && diff.Ownership == nil {
+				diff.Action = DiffActionUnchanged
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+func toJSONValue(obj runtime.Object) (interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal object: %w", err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal object: %w", err)
+	}
+	return v, nil
+}
+
+// diffFields appends the changes from old to new under path to changes.
+// Objects are compared key by key, and lists element by element, matched by
+// their merge key if they have one or by index otherwise.
+func diffFields(path string, old interface{}, new interface{}, changes []FieldChange) []FieldChange {
+	switch oldValue := old.(type) {
+	case map[string]interface{}:
+		newValue, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(oldValue)+len(newValue))
+		for k := range oldValue {
+			keys = append(keys, k)
+		}
+		for k := range newValue {
+			if _, ok := oldValue[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			changes = diffFields(fieldPath(path, k), oldValue[k], newValue[k], changes)
+		}
+		return changes
+	case []interface{}:
+		newValue, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+		if key := listMergeKey(oldValue, newValue); key != "" {
+			return diffKeyedList(path, key, oldValue, newValue, changes)
+		}
+		for i := 0; i < len(oldValue) || i < len(newValue); i++ {
+			var o, n interface{}
+			if i < len(oldValue) {
+				o = oldValue[i]
+			}
+			if i < len(newValue) {
+				n = newValue[i]
+			}
+			changes = diffFields(fmt.Sprintf("%s[%d]", path, i), o, n, changes)
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		changes = append(changes, FieldChange{Path: path, Old: old, New: new})
+	}
+	return changes
+}
+
+// listMergeKeys are the fields commonly identifying the elements of
+// associative lists, e.g. the name of containers, tried in order.
+var listMergeKeys = []string{"name", "mountPath", "devicePath", "containerPort", "port"}
+
+// listMergeKey returns the first of listMergeKeys that all elements of both
+// lists have, with values unique within each list, or "" if none.
+func listMergeKey(old []interface{}, new []interface{}) string {
+	if len(old) == 0 && len(new) == 0 {
+		return ""
+	}
+	for _, key := range listMergeKeys {
+		ok := true
+		for _, list := range [][]interface{}{old, new} {
+			seen := map[string]bool{}
+			for _, elem := range list {
+				value, found := listMergeKeyValue(elem, key)
+				if !found || seen[value] {
+					ok = false
+					break
+				}
+				seen[value] = true
+			}
+		}
+		if ok {
+			return key
+		}
+	}
+	return ""
+}
+
+func listMergeKeyValue(elem interface{}, key string) (string, bool) {
+	m, ok := elem.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	switch value := m[key].(type) {
+	case string, float64:
+		return fmt.Sprint(value), true
+	default:
+		return "", false
+	}
+}
+
+// diffKeyedList compares the elements of lists matched by key, the path of
+// each being a filter as accepted by IgnoreDifferencesRule, e.g.
+// spec.containers[?(@.name=="app")].
+func diffKeyedList(path string, key string, old []interface{}, new []interface{}, changes []FieldChange) []FieldChange {
+	newByKey := map[string]interface{}{}
+	for _, elem := range new {
+		value, _ := listMergeKeyValue(elem, key)
+		newByKey[value] = elem
+	}
+	oldKeys := map[string]bool{}
+	for _, elem := range old {
+		value, _ := listMergeKeyValue(elem, key)
+		oldKeys[value] = true
+		changes = diffFields(fmt.Sprintf("%s[?(@.%s==%q)]", path, key, value), elem, newByKey[value], changes)
+	}
+	for _, elem := range new {
+		if value, _ := listMergeKeyValue(elem, key); !oldKeys[value] {
+			changes = diffFields(fmt.Sprintf("%s[?(@.%s==%q)]", path, key, value), nil, elem, changes)
+		}
+	}
+	return changes
+}
+
+var simpleFieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// fieldPath appends key to path, e.g. spec.replicas or
+// metadata.labels["app.kubernetes.io/name"].
+func fieldPath(path string, key string) string {
+	if !simpleFieldName.MatchString(key) {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,66 @@
+package configset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFields(t *testing.T) {
+	container := func(name string, image string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "image": image}
+	}
+
+	tests := []struct {
+		name string
+		old  interface{}
+		new  interface{}
+		want []FieldChange
+	}{
+		{
+			name: "fields",
+			old:  map[string]interface{}{"data": map[string]interface{}{"a": "1", "b.c": "2"}},
+			new:  map[string]interface{}{"data": map[string]interface{}{"a": "2", "d": "3"}},
+			want: []FieldChange{
+				{Path: "data.a", Old: "1", New: "2"},
+				{Path: `data["b.c"]`, Old: "2"},
+				{Path: "data.d", New: "3"},
+			},
+		},
+		{
+			name: "list by index",
+			old:  map[string]interface{}{"args": []interface{}{"a", "b"}},
+			new:  map[string]interface{}{"args": []interface{}{"x", "a", "b"}},
+			want: []FieldChange{
+				{Path: "args[0]", Old: "a", New: "x"},
+				{Path: "args[1]", Old: "b", New: "a"},
+				{Path: "args[2]", New: "b"},
+			},
+		},
+		{
+			name: "list by merge key",
+			old:  []interface{}{container("app", "app:1"), container("sidecar", "sidecar:1")},
+			new:  []interface{}{container("init", "init:1"), container("app", "app:2"), container("sidecar", "sidecar:1")},
+			want: []FieldChange{
+				{Path: `[?(@.name=="app")].image`, Old: "app:1", New: "app:2"},
+				{Path: `[?(@.name=="init")]`, New: container("init", "init:1")},
+			},
+		},
+		{
+			name: "list with duplicated keys",
+			old:  []interface{}{container("app", "a")},
+			new:  []interface{}{container("app", "b"), container("app", "c")},
+			want: []FieldChange{
+				{Path: "[0].image", Old: "a", New: "b"},
+				{Path: "[1]", New: container("app", "c")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffFields("", tt.old, tt.new, nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
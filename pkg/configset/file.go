@@ -0,0 +1,202 @@
+package configset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DefaultSetInfoFileExt          = ".yaml"
+	DefaultSetInfoFileLockName     = ".configset.lock"
+	DefaultSetInfoFileLockTimeout  = 30 * time.Second
+	defaultSetInfoFileLockInterval = 100 * time.Millisecond
+)
+
+// FileSetInfoStore stores set infos as one YAML file per set in a directory.
+// Files are replaced with atomic renames, and writers are serialized with an
+// advisory lock file in the directory.
+type FileSetInfoStore struct {
+	dir         string
+	ext         string
+	lockName    string
+	lockTimeout time.Duration
+}
+
+var _ SetInfoStore = &FileSetInfoStore{}
+
+func NewFileSetInfoStore(dir string) (*FileSetInfoStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return &FileSetInfoStore{
+		dir:         dir,
+		ext:         DefaultSetInfoFileExt,
+		lockName:    DefaultSetInfoFileLockName,
+		lockTimeout: DefaultSetInfoFileLockTimeout,
+	}, nil
+}
+
+func (s *FileSetInfoStore) GetSetInfo(ctx context.Context, name string) (*SetInfo, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return setInfoFromYAML(b)
+}
+
+func (s *FileSetInfoStore) ListSetInfos(ctx context.Context) ([]*SetInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	infos := make([]*SetInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || filepath.Ext(entry.Name()) != s.ext {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", entry.Name(), err)
+		}
+		info, err := setInfoFromYAML(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", entry.Name(), err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *FileSetInfoStore) CreateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return s.withLock(ctx, func() error {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("set info %s already exists", name)
+		}
+		return s.write(path, info)
+	})
+}
+
+func (s *FileSetInfoStore) UpdateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return s.withLock(ctx, func() error {
+		return s.write(path, info)
+	})
+}
+
+func (s *FileSetInfoStore) DeleteSetInfo(ctx context.Context, name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return s.withLock(ctx, func() error {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *FileSetInfoStore) path(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid set name %q", name)
+	}
+	return filepath.Join(s.dir, name+s.ext), nil
+}
+
+// write replaces the file at path by renaming a fully written temporary file
+// over it, so that readers never see a partially written set info.
+func (s *FileSetInfoStore) write(path string, info *SetInfo) error {
+	b, err := yaml.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to chmod temporary file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return nil
+}
+
+// withLock runs fn while holding the lock file of the directory, waiting for
+// other holders until the lock timeout expires.
+func (s *FileSetInfoStore) withLock(ctx context.Context, fn func() error) error {
+	lockPath := filepath.Join(s.dir, s.lockName)
+	deadline := time.Now().Add(s.lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.WriteString(strconv.Itoa(os.Getpid()))
+			f.Close()
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to create lock file: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for lock file %s, remove it if no other process is holding it", lockPath)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(defaultSetInfoFileLockInterval):
+		}
+	}
+	defer os.Remove(lockPath)
+
+	return fn()
+}
+
+func setInfoFromYAML(b []byte) (*SetInfo, error) {
+	var info SetInfo
+	if err := yaml.Unmarshal(b, &info); err != nil {
+		return nil, err
+	}
+	info.storedSchemaVersion = info.SchemaVersion
+	info.storedPayload = b
+	if err := MigrateSetInfo(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
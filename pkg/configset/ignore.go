@@ -0,0 +1,326 @@
+package configset
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// IgnoreDifferencesRule removes fields from the objects it matches before
+// they are compared, so that differences in them are not reported.
+type IgnoreDifferencesRule struct {
+	// Group and Kind select the objects by type, matching any if empty. Kind
+	// is case-insensitive and may also be "*".
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	// Namespace and Name are patterns as accepted by path.Match, matching any
+	// if empty.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// JSONPointers are RFC 6901 JSON pointers, e.g. /spec/replicas.
+	JSONPointers []string `json:"jsonPointers,omitempty"`
+	// JSONPaths are JSONPath expressions made of field names, quoted keys,
+	// indexes, wildcards and equality filters, e.g.
+	// .webhooks[*].clientConfig.caBundle or
+	// .spec.containers[?(@.name=="app")].image.
+	JSONPaths []string `json:"jsonPaths,omitempty"`
+}
+
+// Validate checks the patterns and paths of the rule.
+func (r IgnoreDifferencesRule) Validate() error {
+	for _, pattern := range []string{r.Namespace, r.Name} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	_, err := r.paths()
+	return err
+}
+
+func (r IgnoreDifferencesRule) matches(obj Object) bool {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if r.Group != "" && r.Group != gvk.Group {
+		return false
+	}
+	if r.Kind != "" && r.Kind != "*" && !strings.EqualFold(r.Kind, gvk.Kind) {
+		return false
+	}
+	if ok, _ := path.Match(r.Namespace, obj.GetNamespace()); r.Namespace != "" && !ok {
+		return false
+	}
+	if ok, _ := path.Match(r.Name, obj.GetName()); r.Name != "" && !ok {
+		return false
+	}
+	return true
+}
+
+func (r IgnoreDifferencesRule) paths() ([][]pathSegment, error) {
+	var paths [][]pathSegment
+	for _, pointer := range r.JSONPointers {
+		segments, err := parseJSONPointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, segments)
+	}
+	for _, jsonPath := range r.JSONPaths {
+		segments, err := parseJSONPath(jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, segments)
+	}
+	return paths, nil
+}
+
+// ignoreDifferences removes the fields selected by the rules matching obj from
+// it, converting it to an unstructured object if needed.
+func ignoreDifferences(obj runtime.Object, rules []IgnoreDifferencesRule) (runtime.Object, error) {
+	var paths [][]pathSegment
+	for _, rule := range rules {
+		if !rule.matches(obj.(Object)) {
+			continue
+		}
+		rulePaths, err := rule.paths()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, rulePaths...)
+	}
+	if len(paths) == 0 {
+		return obj, nil
+	}
+
+	un, err := toUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	content := un.UnstructuredContent()
+	for _, segments := range paths {
+		removePath(content, segments)
+	}
+	un.SetUnstructuredContent(compact(content).(map[string]interface{}))
+	return un, nil
+}
+
+type pathSegment struct {
+	// key is an object key, or a list index if the value is a list.
+	key      string
+	wildcard bool
+	filter   *pathFilter
+}
+
+// pathFilter selects list elements whose field equals a value.
+type pathFilter struct {
+	field string
+	value string
+}
+
+func (f *pathFilter) matches(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	field, ok := m[f.field]
+	return ok && fmt.Sprint(field) == f.value
+}
+
+func parseJSONPointer(pointer string) ([]pathSegment, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+	var segments []pathSegment
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+		segments = append(segments, pathSegment{key: token})
+	}
+	return segments, nil
+}
+
+func parseJSONPath(jsonPath string) ([]pathSegment, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid JSONPath %q: %s", jsonPath, reason)
+	}
+
+	p := strings.TrimSpace(jsonPath)
+	if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+		p = p[1 : len(p)-1]
+	}
+	p = strings.TrimPrefix(p, "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p
+	}
+
+	var segments []pathSegment
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			if strings.HasPrefix(p, ".") {
+				return nil, invalid("recursive descent is not supported")
+			}
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, invalid("empty field name")
+			}
+			if p[:end] == "*" {
+				segments = append(segments, pathSegment{wildcard: true})
+			} else {
+				segments = append(segments, pathSegment{key: p[:end]})
+			}
+			p = p[end:]
+		case '[':
+			end := closingBracket(p)
+			if end < 0 {
+				return nil, invalid("unterminated '['")
+			}
+			segment, err := parseBracket(p[1:end])
+			if err != nil {
+				return nil, invalid(err.Error())
+			}
+			segments = append(segments, segment)
+			p = p[end+1:]
+		default:
+			return nil, invalid(fmt.Sprintf("unexpected %q", p[0]))
+		}
+	}
+	if len(segments) == 0 {
+		return nil, invalid("empty path")
+	}
+	return segments, nil
+}
+
+// closingBracket returns the index of the ']' closing the '[' at the start of
+// p, skipping quoted strings.
+func closingBracket(p string) int {
+	var quote byte
+	for i := 1; i < len(p); i++ {
+		switch {
+		case quote != 0:
+			if p[i] == quote {
+				quote = 0
+			}
+		case p[i] == '\'' || p[i] == '"':
+			quote = p[i]
+		case p[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBracket(s string) (pathSegment, error) {
+	switch {
+	case s == "*":
+		return pathSegment{wildcard: true}, nil
+	case isQuoted(s):
+		return pathSegment{key: s[1 : len(s)-1]}, nil
+	case strings.HasPrefix(s, "?(") && strings.HasSuffix(s, ")"):
+		field, value, ok := strings.Cut(s[2:len(s)-1], "==")
+		field, value = strings.TrimSpace(field), strings.TrimSpace(value)
+		if !ok || !strings.HasPrefix(field, "@.") || len(field) == 2 {
+			return pathSegment{}, fmt.Errorf("only filters like ?(@.field==\"value\") are supported")
+		}
+		if isQuoted(value) {
+			value = value[1 : len(value)-1]
+		}
+		return pathSegment{filter: &pathFilter{field: field[2:], value: value}}, nil
+	default:
+		if _, err := strconv.Atoi(s); err != nil {
+			return pathSegment{}, fmt.Errorf("unsupported subscript %q", s)
+		}
+		return pathSegment{key: s}, nil
+	}
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]
+}
+
+// removedElement replaces the list elements removed by removePath until
+// compact drops them, so that the indexes of all paths refer to the original
+// lists.
+type removedElement struct{}
+
+// removePath removes the values selected by segments from v, list elements
+// being replaced with removedElement.
+func removePath(v interface{}, segments []pathSegment) {
+	if len(segments) == 0 {
+		return
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if segment.filter != nil {
+			return
+		}
+		for k, child := range value {
+			if !segment.wildcard && k != segment.key {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(value, k)
+			} else {
+				removePath(child, rest)
+			}
+		}
+	case []interface{}:
+		index := -1
+		if !segment.wildcard && segment.filter == nil {
+			i, err := strconv.Atoi(segment.key)
+			if err != nil {
+				return
+			}
+			index = i
+		}
+		for i, child := range value {
+			if _, ok := child.(removedElement); ok {
+				continue
+			}
+			switch {
+			case segment.wildcard:
+			case segment.filter != nil:
+				if !segment.filter.matches(child) {
+					continue
+				}
+			case i != index:
+				continue
+			}
+			if len(rest) == 0 {
+				value[i] = removedElement{}
+			} else {
+				removePath(child, rest)
+			}
+		}
+	}
+}
+
+// compact drops the list elements removed by removePath from v, returning the
+// resulting value, as doing so creates new lists.
+func compact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			value[k] = compact(child)
+		}
+		return value
+	case []interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, child := range value {
+			if _, ok := child.(removedElement); !ok {
+				result = append(result, compact(child))
+			}
+		}
+		return result
+	default:
+		return v
+	}
+}
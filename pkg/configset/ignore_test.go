@@ -0,0 +1,304 @@
+package configset
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseJSONPointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		want    []pathSegment
+		err     string
+	}{
+		{
+			pointer: "/spec/replicas",
+			want:    []pathSegment{{key: "spec"}, {key: "replicas"}},
+		},
+		{
+			pointer: "/spec/containers/0/image",
+			want:    []pathSegment{{key: "spec"}, {key: "containers"}, {key: "0"}, {key: "image"}},
+		},
+		{
+			pointer: "/metadata/annotations/a~1b~0c",
+			want:    []pathSegment{{key: "metadata"}, {key: "annotations"}, {key: "a/b~c"}},
+		},
+		{
+			// ~1 is unescaped first, so ~01 is ~1 rather than /
+			pointer: "/data/~01",
+			want:    []pathSegment{{key: "data"}, {key: "~1"}},
+		},
+		{
+			pointer: "spec/replicas",
+			err:     "must start with '/'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			got, err := parseJSONPointer(tt.pointer)
+			checkParsedPath(t, got, err, tt.want, tt.err)
+		})
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []pathSegment
+		err  string
+	}{
+		{
+			path: ".spec.replicas",
+			want: []pathSegment{{key: "spec"}, {key: "replicas"}},
+		},
+		{
+			path: "{$.spec.replicas}",
+			want: []pathSegment{{key: "spec"}, {key: "replicas"}},
+		},
+		{
+			path: "spec.containers[0].image",
+			want: []pathSegment{{key: "spec"}, {key: "containers"}, {key: "0"}, {key: "image"}},
+		},
+		{
+			path: ".webhooks[*].clientConfig.caBundle",
+			want: []pathSegment{{key: "webhooks"}, {wildcard: true}, {key: "clientConfig"}, {key: "caBundle"}},
+		},
+		{
+			path: ".metadata.annotations.*",
+			want: []pathSegment{{key: "metadata"}, {key: "annotations"}, {wildcard: true}},
+		},
+		{
+			path: `.spec.containers[?(@.name=="app")].image`,
+			want: []pathSegment{{key: "spec"}, {key: "containers"}, {filter: &pathFilter{field: "name", value: "app"}}, {key: "image"}},
+		},
+		{
+			path: `.spec.ports[?(@.port == 80)]`,
+			want: []pathSegment{{key: "spec"}, {key: "ports"}, {filter: &pathFilter{field: "port", value: "80"}}},
+		},
+		{
+			path: `.metadata.annotations['example.com/a.b']`,
+			want: []pathSegment{{key: "metadata"}, {key: "annotations"}, {key: "example.com/a.b"}},
+		},
+		{
+			path: `.data["a]b"].c`,
+			want: []pathSegment{{key: "data"}, {key: "a]b"}, {key: "c"}},
+		},
+		{path: "..x", err: "recursive descent is not supported"},
+		{path: ".spec[0", err: "unterminated '['"},
+		{path: `.data["a]`, err: "unterminated '['"},
+		{path: ".spec.", err: "empty field name"},
+		{path: ".spec[name]", err: "unsupported subscript"},
+		{path: ".spec[?(@.name)]", err: "only filters like"},
+		{path: "$", err: "empty path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parseJSONPath(tt.path)
+			checkParsedPath(t, got, err, tt.want, tt.err)
+		})
+	}
+}
+
+func checkParsedPath(t *testing.T, got []pathSegment, err error, want []pathSegment, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("got error %v, want %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestIgnoreDifferencesRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule IgnoreDifferencesRule
+		err  string
+	}{
+		{
+			name: "valid",
+			rule: IgnoreDifferencesRule{Kind: "Deployment", Name: "web-*", JSONPointers: []string{"/spec/replicas"}, JSONPaths: []string{".spec.template.metadata.annotations"}},
+		},
+		{
+			name: "recursive descent",
+			rule: IgnoreDifferencesRule{JSONPaths: []string{"..x"}},
+			err:  "recursive descent",
+		},
+		{
+			name: "unterminated bracket",
+			rule: IgnoreDifferencesRule{JSONPaths: []string{".spec[*"}},
+			err:  "unterminated",
+		},
+		{
+			name: "relative pointer",
+			rule: IgnoreDifferencesRule{JSONPointers: []string{"spec"}},
+			err:  "must start with",
+		},
+		{
+			name: "bad name pattern",
+			rule: IgnoreDifferencesRule{Name: "web-["},
+			err:  "invalid pattern",
+		},
+		{
+			name: "bad namespace pattern",
+			rule: IgnoreDifferencesRule{Namespace: `\`},
+			err:  "invalid pattern",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestIgnoreDifferencesRuleMatches(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	obj.SetNamespace("prod")
+	obj.SetName("web-1")
+
+	tests := []struct {
+		name string
+		rule IgnoreDifferencesRule
+		want bool
+	}{
+		{name: "any", rule: IgnoreDifferencesRule{}, want: true},
+		{name: "group and kind", rule: IgnoreDifferencesRule{Group: "apps", Kind: "Deployment"}, want: true},
+		{name: "kind case-insensitively", rule: IgnoreDifferencesRule{Kind: "deployment"}, want: true},
+		{name: "wildcard kind", rule: IgnoreDifferencesRule{Kind: "*"}, want: true},
+		{name: "other group", rule: IgnoreDifferencesRule{Group: "extensions", Kind: "Deployment"}},
+		{name: "other kind", rule: IgnoreDifferencesRule{Kind: "StatefulSet"}},
+		{name: "namespace pattern", rule: IgnoreDifferencesRule{Namespace: "pro?"}, want: true},
+		{name: "other namespace", rule: IgnoreDifferencesRule{Namespace: "staging"}},
+		{name: "name pattern", rule: IgnoreDifferencesRule{Name: "web-*"}, want: true},
+		{name: "other name", rule: IgnoreDifferencesRule{Name: "api-*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(obj); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreDifferences(t *testing.T) {
+	newObject := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":        "web",
+				"annotations": map[string]interface{}{"example.com/a.b": "1", "c": "2"},
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(3),
+				"args":     []interface{}{"a", "b", "c", "d"},
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:1"},
+					map[string]interface{}{"name": "sidecar", "image": "sidecar:1"},
+				},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name string
+		rule IgnoreDifferencesRule
+		want func(obj map[string]interface{})
+	}{
+		{
+			name: "pointer",
+			rule: IgnoreDifferencesRule{JSONPointers: []string{"/spec/replicas"}},
+			want: func(obj map[string]interface{}) {
+				delete(obj["spec"].(map[string]interface{}), "replicas")
+			},
+		},
+		{
+			name: "list indexes refer to the original list",
+			rule: IgnoreDifferencesRule{JSONPointers: []string{"/spec/args/0", "/spec/args/1"}, JSONPaths: []string{".spec.args[3]"}},
+			want: func(obj map[string]interface{}) {
+				obj["spec"].(map[string]interface{})["args"] = []interface{}{"c"}
+			},
+		},
+		{
+			name: "wildcard",
+			rule: IgnoreDifferencesRule{JSONPaths: []string{".spec.containers[*].image"}},
+			want: func(obj map[string]interface{}) {
+				obj["spec"].(map[string]interface{})["containers"] = []interface{}{
+					map[string]interface{}{"name": "app"},
+					map[string]interface{}{"name": "sidecar"},
+				}
+			},
+		},
+		{
+			name: "filter",
+			rule: IgnoreDifferencesRule{JSONPaths: []string{`.spec.containers[?(@.name=="app")].image`}},
+			want: func(obj map[string]interface{}) {
+				obj["spec"].(map[string]interface{})["containers"] = []interface{}{
+					map[string]interface{}{"name": "app"},
+					map[string]interface{}{"name": "sidecar", "image": "sidecar:1"},
+				}
+			},
+		},
+		{
+			name: "element by filter",
+			rule: IgnoreDifferencesRule{JSONPaths: []string{`.spec.containers[?(@.name=="app")]`, ".spec.containers[1].image"}},
+			want: func(obj map[string]interface{}) {
+				obj["spec"].(map[string]interface{})["containers"] = []interface{}{
+					map[string]interface{}{"name": "sidecar"},
+				}
+			},
+		},
+		{
+			name: "quoted key",
+			rule: IgnoreDifferencesRule{JSONPaths: []string{`.metadata.annotations['example.com/a.b']`}},
+			want: func(obj map[string]interface{}) {
+				delete(obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{}), "example.com/a.b")
+			},
+		},
+		{
+			name: "missing fields",
+			rule: IgnoreDifferencesRule{JSONPointers: []string{"/spec/missing/x", "/spec/args/9", "/spec/replicas/x"}},
+			want: func(obj map[string]interface{}) {},
+		},
+		{
+			name: "other kind",
+			rule: IgnoreDifferencesRule{Kind: "StatefulSet", JSONPointers: []string{"/spec/replicas"}},
+			want: func(obj map[string]interface{}) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ignoreDifferences(newObject(), []IgnoreDifferencesRule{tt.rule})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := newObject()
+			tt.want(want.Object)
+			if !reflect.DeepEqual(got.(*unstructured.Unstructured).Object, want.Object) {
+				t.Errorf("got %v, want %v", got.(*unstructured.Unstructured).Object, want.Object)
+			}
+		})
+	}
+}
@@ -3,9 +3,34 @@ package configset
 import "context"
 
 type SetInfo struct {
-	Name      string         `json:"name"`
-	Resources []ResourceInfo `json:"resources"`
-	UpdatedAt string         `json:"updatedAt"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Name          string         `json:"name"`
+	Resources     []ResourceInfo `json:"resources"`
+	UpdatedAt     string         `json:"updatedAt"`
+
+	Labels      map[string]string `json:"labels,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Provenance  *Provenance       `json:"provenance,omitempty"`
+
+	// the schema version and payload as read by the stores of this package,
+	// before migration
+	storedSchemaVersion int
+	storedPayload       []byte
+}
+
+// Provenance describes where the last apply of a set came from.
+type Provenance struct {
+	// KubeconfigUser is the name of the user entry of the kubeconfig used,
+	// which isn't necessarily the identity the apiserver authenticated.
+	KubeconfigUser   string `json:"kubeconfigUser,omitempty"`
+	ConfigsetVersion string `json:"configsetVersion,omitempty"`
+	// CommandLine is the command line of the apply, without the flags that may
+	// hold credentials, e.g. --token.
+	CommandLine []string `json:"commandLine,omitempty"`
+	GitCommit   string   `json:"gitCommit,omitempty"`
+	GitDirty    bool     `json:"gitDirty,omitempty"`
+	// FileDigests maps source files to their SHA-256 digests.
+	FileDigests map[string]string `json:"fileDigests,omitempty"`
 }
 
 type ResourceInfo struct {
@@ -23,3 +48,31 @@ type SetInfoStore interface {
 	UpdateSetInfo(ctx context.Context, name string, info *SetInfo) error
 	DeleteSetInfo(ctx context.Context, name string) error
 }
+
+type NamespacedSetInfo struct {
+	Namespace string
+	*SetInfo
+}
+
+// AllNamespacesSetInfoLister is implemented by stores that can list set infos
+// across namespaces. Namespaces that can't be read are skipped and returned.
+type AllNamespacesSetInfoLister interface {
+	ListAllNamespacesSetInfos(ctx context.Context) (infos []NamespacedSetInfo, skippedNamespaces []string, err error)
+}
+
+type ApplyStatus struct {
+	Succeeded bool
+	Message   string
+}
+
+// ApplyStatusRecorder is implemented by stores that can keep the outcome of
+// the last apply of a set.
+type ApplyStatusRecorder interface {
+	RecordApplyStatus(ctx context.Context, name string, status ApplyStatus) error
+}
+
+// SetInfoRenamer is implemented by stores that keep state along with set
+// infos, e.g. a revision, which renaming a set must carry over.
+type SetInfoRenamer interface {
+	RenameSetInfo(ctx context.Context, oldName string, newName string) error
+}
@@ -0,0 +1,91 @@
+package configset
+
+import (
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	maskedValue       = "***"
+	maskedValueBefore = "*** (before)"
+	maskedValueAfter  = "*** (after)"
+)
+
+var secretGroupKind = schema.GroupKind{Kind: "Secret"}
+
+// maskSecrets replaces the values of data and stringData of the live and
+// updated objects if they are Secrets, the same way as kubectl diff does.
+// Values that differ between the two are masked with distinct placeholders so
+// that changes remain visible.
+func maskSecrets(live runtime.Object, updated runtime.Object) (runtime.Object, runtime.Object, error) {
+	isSecret := func(obj runtime.Object) bool {
+		return obj != nil && obj.GetObjectKind().GroupVersionKind().GroupKind() == secretGroupKind
+	}
+	if !isSecret(live) && !isSecret(updated) {
+		return live, updated, nil
+	}
+
+	var liveSecret, updatedSecret *unstructured.Unstructured
+	var err error
+	if live != nil {
+		if liveSecret, err = toUnstructured(live); err != nil {
+			return nil, nil, err
+		}
+	}
+	if updated != nil {
+		if updatedSecret, err = toUnstructured(updated); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, field := range []string{"data", "stringData"} {
+		var liveData, updatedData map[string]interface{}
+		if liveSecret != nil {
+			liveData, _ = liveSecret.Object[field].(map[string]interface{})
+		}
+		if updatedSecret != nil {
+			updatedData, _ = updatedSecret.Object[field].(map[string]interface{})
+		}
+		for k, v := range liveData {
+			updatedValue, ok := updatedData[k]
+			if ok && !reflect.DeepEqual(v, updatedValue) {
+				liveData[k] = maskedValueBefore
+				updatedData[k] = maskedValueAfter
+				continue
+			}
+			liveData[k] = maskedValue
+			if ok {
+				updatedData[k] = maskedValue
+			}
+		}
+		for k := range updatedData {
+			if _, ok := liveData[k]; !ok {
+				updatedData[k] = maskedValue
+			}
+		}
+	}
+
+	// keep absent objects nil rather than typed nil pointers
+	if liveSecret != nil {
+		live = liveSecret
+	}
+	if updatedSecret != nil {
+		updated = updatedSecret
+	}
+	return live, updated, nil
+}
+
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if un, ok := obj.(*unstructured.Unstructured); ok {
+		return un, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object to unstructured: %w", err)
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
@@ -0,0 +1,199 @@
+package configset
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newSecret returns an unstructured Secret with the given data and
+// stringData, left out if nil.
+func newSecret(data map[string]interface{}, stringData map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": "creds"},
+	}}
+	if data != nil {
+		obj.Object["data"] = data
+	}
+	if stringData != nil {
+		obj.Object["stringData"] = stringData
+	}
+	return obj
+}
+
+func TestMaskSecrets(t *testing.T) {
+	values := func(same, changed, removedOrAdded string) map[string]interface{} {
+		m := map[string]interface{}{"same": same, "changed": changed}
+		if removedOrAdded != "" {
+			m[removedOrAdded] = "v-" + removedOrAdded
+		}
+		return m
+	}
+	masked := func(changed string, removedOrAdded string) map[string]interface{} {
+		m := map[string]interface{}{"same": maskedValue, "changed": changed}
+		if removedOrAdded != "" {
+			m[removedOrAdded] = maskedValue
+		}
+		return m
+	}
+
+	tests := []struct {
+		name        string
+		live        runtime.Object
+		updated     runtime.Object
+		wantLive    runtime.Object
+		wantUpdated runtime.Object
+	}{
+		{
+			name:        "update",
+			live:        newSecret(values("a", "old", "removed"), values("b", "old", "removed")),
+			updated:     newSecret(values("a", "new", "added"), values("b", "new", "added")),
+			wantLive:    newSecret(masked(maskedValueBefore, "removed"), masked(maskedValueBefore, "removed")),
+			wantUpdated: newSecret(masked(maskedValueAfter, "added"), masked(maskedValueAfter, "added")),
+		},
+		{
+			name:        "field added",
+			live:        newSecret(values("a", "old", ""), nil),
+			updated:     newSecret(values("a", "new", ""), values("b", "c", "")),
+			wantLive:    newSecret(masked(maskedValueBefore, ""), nil),
+			wantUpdated: newSecret(masked(maskedValueAfter, ""), masked(maskedValue, "")),
+		},
+		{
+			name:        "create",
+			updated:     newSecret(values("a", "b", ""), values("c", "d", "")),
+			wantUpdated: newSecret(masked(maskedValue, ""), masked(maskedValue, "")),
+		},
+		{
+			name:     "delete",
+			live:     newSecret(values("a", "b", ""), values("c", "d", "")),
+			wantLive: newSecret(masked(maskedValue, ""), masked(maskedValue, "")),
+		},
+		{
+			name: "typed",
+			live: &corev1.Secret{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "creds"},
+				Data:       map[string][]byte{"same": []byte("a"), "changed": []byte("old")},
+			},
+			updated: &corev1.Secret{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "creds"},
+				Data:       map[string][]byte{"same": []byte("a"), "changed": []byte("new")},
+				StringData: map[string]string{"added": "b"},
+			},
+			wantLive:    newSecret(masked(maskedValueBefore, ""), nil),
+			wantUpdated: newSecret(masked(maskedValueAfter, ""), map[string]interface{}{"added": maskedValue}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live, updated, err := maskSecrets(tt.live, tt.updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				side      string
+				got, want runtime.Object
+			}{{"live", live, tt.wantLive}, {"updated", updated, tt.wantUpdated}} {
+				if c.want == nil {
+					if c.got != nil {
+						t.Errorf("%s is %v, want nil", c.side, c.got)
+					}
+					continue
+				}
+				got, _ := c.got.(*unstructured.Unstructured)
+				want := c.want.(*unstructured.Unstructured)
+				if got == nil {
+					t.Errorf("%s is %v, want %v", c.side, c.got, want.Object)
+					continue
+				}
+				for _, field := range []string{"data", "stringData"} {
+					if !reflect.DeepEqual(got.Object[field], want.Object[field]) {
+						t.Errorf("%s %s is %v, want %v", c.side, field, got.Object[field], want.Object[field])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMaskSecretsLeavesOtherObjects(t *testing.T) {
+	configMap := func(value string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"namespace": "default", "name": "config"},
+			"data":       map[string]interface{}{"key": value},
+		}}
+	}
+	live, updated := configMap("old"), configMap("new")
+	gotLive, gotUpdated, err := maskSecrets(live, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotLive, configMap("old")) || !reflect.DeepEqual(gotUpdated, configMap("new")) {
+		t.Errorf("config maps were masked: %v, %v", gotLive, gotUpdated)
+	}
+}
+
+func TestSecretsMaskedInDiffs(t *testing.T) {
+	results := []ObjectResult{
+		{
+			Live:    newSecret(map[string]interface{}{"password": "b2xkLXNlY3JldA=="}, nil),
+			Updated: newSecret(map[string]interface{}{"password": "bmV3LXNlY3JldA=="}, map[string]interface{}{"token": "plain-token"}),
+		},
+	}
+	rawValues := []string{"b2xkLXNlY3JldA==", "bmV3LXNlY3JldA==", "plain-token"}
+
+	outputs := func(t *testing.T, opt AddObjectResultsToDifferOptions) map[string]string {
+		diffs, err := DiffObjectResults(results, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fieldDiff, err := json.Marshal(diffs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{"DiffObjectResults": string(fieldDiff)}
+		for _, format := range []DiffReportFormat{DiffReportMarkdown, DiffReportHTML} {
+			var buf bytes.Buffer
+			if err := WriteDiffReport(&buf, results, DiffReportOptions{Format: format, SetName: "app", ContextLines: 3, DiffOptions: opt}); err != nil {
+				t.Fatal(err)
+			}
+			got[string(format)+" report"] = buf.String()
+		}
+		return got
+	}
+
+	for name, output := range outputs(t, CleanDiffOptions()) {
+		for _, raw := range rawValues {
+			if strings.Contains(output, raw) {
+				t.Errorf("%s shows the secret value %s:\n%s", name, raw, output)
+			}
+		}
+		for _, placeholder := range []string{maskedValueBefore, maskedValueAfter} {
+			if !strings.Contains(output, placeholder) {
+				t.Errorf("%s doesn't show %q:\n%s", name, placeholder, output)
+			}
+		}
+	}
+
+	opt := CleanDiffOptions()
+	opt.ShowSecrets = true
+	for name, output := range outputs(t, opt) {
+		for _, raw := range rawValues {
+			if !strings.Contains(output, raw) {
+				t.Errorf("%s with ShowSecrets doesn't show %s:\n%s", name, raw, output)
+			}
+		}
+	}
+}
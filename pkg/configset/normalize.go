@@ -0,0 +1,109 @@
+package configset
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Normalizer modifies an object before it is compared, e.g. to undo a quirk
+// of the apiserver that would show up as a difference.
+type Normalizer func(obj *unstructured.Unstructured) error
+
+// NormalizerRegistry holds the normalizers of each GroupVersionKind.
+type NormalizerRegistry struct {
+	mu          sync.RWMutex
+	normalizers map[schema.GroupVersionKind][]namedNormalizer
+}
+
+type namedNormalizer struct {
+	name      string
+	normalize Normalizer
+}
+
+func NewNormalizerRegistry() *NormalizerRegistry {
+	return &NormalizerRegistry{
+		normalizers: map[schema.GroupVersionKind][]namedNormalizer{},
+	}
+}
+
+// Register adds a normalizer for objects of gvk, run after the ones already
+// registered.
+func (r *NormalizerRegistry) Register(gvk schema.GroupVersionKind, normalizer Normalizer) {
+	r.RegisterNamed("", gvk, normalizer)
+}
+
+// RegisterNamed is like Register, the normalizer being skipped when its name
+// is passed to Normalize.
+func (r *NormalizerRegistry) RegisterNamed(name string, gvk schema.GroupVersionKind, normalizer Normalizer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.normalizers[gvk] = append(r.normalizers[gvk], namedNormalizer{name: name, normalize: normalizer})
+}
+
+// Normalize runs the normalizers registered for the kind of obj on it, except
+// the ones named in skip, converting it to an unstructured object if any.
+func (r *NormalizerRegistry) Normalize(obj runtime.Object, skip ...string) (runtime.Object, error) {
+	r.mu.RLock()
+	normalizers := lo.Filter(r.normalizers[obj.GetObjectKind().GroupVersionKind()], func(n namedNormalizer, _ int) bool {
+		return n.name == "" || !lo.Contains(skip, n.name)
+	})
+	r.mu.RUnlock()
+	if len(normalizers) == 0 {
+		return obj, nil
+	}
+
+	un, err := toUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	for _, normalizer := range normalizers {
+		if err := normalizer.normalize(un); err != nil {
+			return nil, err
+		}
+	}
+	return un, nil
+}
+
+// DefaultNormalizerRegistry is used unless other normalizers are given in
+// AddObjectResultsToDifferOptions. It ships with the built-in normalizers for
+// known quirks of the apiserver.
+var DefaultNormalizerRegistry = NewNormalizerRegistry()
+
+// HorizontalPodAutoscalerMetricsNormalizer names the built-in normalizer
+// dropping the duplicated metrics of autoscaling/v2beta2
+// HorizontalPodAutoscalers, run if
+// AddObjectResultsToDifferOptions.FixAutoscalingV2Beta2HorizontalPodAutoscaler
+// is set.
+const HorizontalPodAutoscalerMetricsNormalizer = "autoscaling-v2beta2-hpa-metrics"
+
+func init() {
+	DefaultNormalizerRegistry.RegisterNamed(HorizontalPodAutoscalerMetricsNormalizer, autoscalingV2Beta2HorizontalPodAutoscaler, dedupHorizontalPodAutoscalerMetrics)
+}
+
+// RegisterNormalizer adds a normalizer for objects of gvk to
+// DefaultNormalizerRegistry.
+func RegisterNormalizer(gvk schema.GroupVersionKind, normalizer Normalizer) {
+	DefaultNormalizerRegistry.Register(gvk, normalizer)
+}
+
+var autoscalingV2Beta2HorizontalPodAutoscaler = schema.FromAPIVersionAndKind("autoscaling/v2beta2", "HorizontalPodAutoscaler")
+
+// dedupHorizontalPodAutoscalerMetrics removes duplicated metrics, which the
+// apiserver returns for autoscaling/v2beta2 HorizontalPodAutoscalers converted
+// from other versions.
+func dedupHorizontalPodAutoscalerMetrics(obj *unstructured.Unstructured) error {
+	metrics, ok, err := unstructured.NestedSlice(obj.Object, "spec", "metrics")
+	if err != nil || !ok {
+		return err
+	}
+	metrics = lo.UniqBy(metrics, func(item interface{}) string {
+		b, _ := json.Marshal(item)
+		return string(b)
+	})
+	return unstructured.SetNestedSlice(obj.Object, metrics, "spec", "metrics")
+}
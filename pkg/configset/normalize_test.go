@@ -0,0 +1,88 @@
+package configset_test
+
+import (
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newHorizontalPodAutoscaler(metrics ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2beta2",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": "app"},
+		"spec":       map[string]interface{}{"metrics": metrics},
+	}}
+}
+
+func TestHorizontalPodAutoscalerMetricsNormalizer(t *testing.T) {
+	cpu := map[string]interface{}{"type": "Resource", "resource": map[string]interface{}{"name": "cpu"}}
+	results := []configset.ObjectResult{{
+		Live:    newHorizontalPodAutoscaler(cpu, cpu),
+		Updated: newHorizontalPodAutoscaler(cpu),
+	}}
+
+	tests := []struct {
+		name string
+		opt  configset.AddObjectResultsToDifferOptions
+		want configset.DiffAction
+	}{
+		{
+			name: "enabled",
+			opt:  configset.AddObjectResultsToDifferOptions{FixAutoscalingV2Beta2HorizontalPodAutoscaler: true},
+			want: configset.DiffActionUnchanged,
+		},
+		{
+			name: "clean profile",
+			opt:  configset.CleanDiffOptions(),
+			want: configset.DiffActionUnchanged,
+		},
+		{
+			name: "disabled",
+			opt:  configset.AddObjectResultsToDifferOptions{},
+			want: configset.DiffActionUpdate,
+		},
+		{
+			name: "other registry",
+			opt: configset.AddObjectResultsToDifferOptions{
+				FixAutoscalingV2Beta2HorizontalPodAutoscaler: true,
+				Normalizers: configset.NewNormalizerRegistry(),
+			},
+			want: configset.DiffActionUpdate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := configset.DiffObjectResults(results, tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diffs) != 1 || diffs[0].Action != tt.want {
+				t.Errorf("got %+v, want action %s", diffs, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultNormalizerRegistry(t *testing.T) {
+	cpu := map[string]interface{}{"type": "Resource", "resource": map[string]interface{}{"name": "cpu"}}
+
+	normalized, err := configset.DefaultNormalizerRegistry.Normalize(newHorizontalPodAutoscaler(cpu, cpu))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, _, _ := unstructured.NestedSlice(normalized.(*unstructured.Unstructured).Object, "spec", "metrics")
+	if len(metrics) != 1 {
+		t.Errorf("got %d metrics, want 1", len(metrics))
+	}
+
+	skipped, err := configset.DefaultNormalizerRegistry.Normalize(newHorizontalPodAutoscaler(cpu, cpu), configset.HorizontalPodAutoscalerMetricsNormalizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, _, _ = unstructured.NestedSlice(skipped.(*unstructured.Unstructured).Object, "spec", "metrics")
+	if len(metrics) != 2 {
+		t.Errorf("got %d metrics with the normalizer skipped, want 2", len(metrics))
+	}
+}
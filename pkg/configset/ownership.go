@@ -0,0 +1,147 @@
+package configset
+
+import (
+	"bytes"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// OwnershipChanges lists the fields, as structured merge diff paths, that a
+// field manager starts or stops owning.
+type OwnershipChanges struct {
+	Acquired []string `json:"acquired,omitempty"`
+	Released []string `json:"released,omitempty"`
+}
+
+func (o *OwnershipChanges) empty() bool {
+	return o == nil || len(o.Acquired) == 0 && len(o.Released) == 0
+}
+
+// ownedFields returns the fields applied by manager to obj, empty if obj is nil.
+func ownedFields(obj Object, manager string) (*fieldpath.Set, error) {
+	set := &fieldpath.Set{}
+	if obj == nil {
+		return set, nil
+	}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != manager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := &fieldpath.Set{}
+		if err := fields.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+			return nil, fmt.Errorf("failed to parse managed fields of %s: %w", obj.GetName(), err)
+		}
+		set = set.Union(fields)
+	}
+	return set, nil
+}
+
+// ownershipChanges compares the fields owned before and after, leaving out
+// the changes implied by creating or deleting the object.
+func ownershipChanges(before *fieldpath.Set, after *fieldpath.Set, result ObjectResult) *OwnershipChanges {
+	if result.Live == nil || result.Updated == nil {
+		return nil
+	}
+	changes := &OwnershipChanges{}
+	after.Difference(before).Leaves().Iterate(func(p fieldpath.Path) {
+		changes.Acquired = append(changes.Acquired, p.String())
+	})
+	before.Difference(after).Leaves().Iterate(func(p fieldpath.Path) {
+		changes.Released = append(changes.Released, p.String())
+	})
+	return changes
+}
+
+// filterOwnedFields keeps the fields of obj within owned, along with the
+// fields identifying it.
+func filterOwnedFields(obj runtime.Object, owned *fieldpath.Set) (runtime.Object, error) {
+	un, err := toUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	filtered := filterFields(un.UnstructuredContent(), owned).(map[string]interface{})
+	filtered["apiVersion"] = un.GetAPIVersion()
+	filtered["kind"] = un.GetKind()
+	metadata, _ := filtered["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["name"] = un.GetName()
+	if un.GetNamespace() != "" {
+		metadata["namespace"] = un.GetNamespace()
+	}
+	filtered["metadata"] = metadata
+	un.SetUnstructuredContent(filtered)
+	return un, nil
+}
+
+// filterFields returns a copy of v with only the fields in set. Fields with
+// children in set are filtered recursively, and the other members of set are
+// kept as a whole.
+func filterFields(v interface{}, set *fieldpath.Set) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, child := range value {
+			name := k
+			pe := fieldpath.PathElement{FieldName: &name}
+			if childSet, ok := set.Children.Get(pe); ok {
+				result[k] = filterFields(child, childSet)
+			} else if set.Members.Has(pe) {
+				result[k] = child
+			}
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for i, child := range value {
+			var childSet *fieldpath.Set
+			set.Children.Iterate(func(pe fieldpath.PathElement) {
+				if childSet == nil && listElementMatches(pe, i, child) {
+					childSet, _ = set.Children.Get(pe)
+				}
+			})
+			if childSet != nil {
+				result = append(result, filterFields(child, childSet))
+				continue
+			}
+			member := false
+			set.Members.Iterate(func(pe fieldpath.PathElement) {
+				member = member || listElementMatches(pe, i, child)
+			})
+			if member {
+				result = append(result, child)
+			}
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func listElementMatches(pe fieldpath.PathElement, index int, elem interface{}) bool {
+	switch {
+	case pe.Index != nil:
+		return *pe.Index == index
+	case pe.Value != nil:
+		return value.Equals(value.NewValueInterface(elem), *pe.Value)
+	case pe.Key != nil:
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, field := range *pe.Key {
+			v, ok := m[field.Name]
+			if !ok || !value.Equals(value.NewValueInterface(v), field.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
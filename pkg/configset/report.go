@@ -0,0 +1,215 @@
+package configset
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/wxdao/configset/pkg/diffutil"
+)
+
+type DiffReportFormat string
+
+const (
+	DiffReportMarkdown DiffReportFormat = "markdown"
+	DiffReportHTML     DiffReportFormat = "html"
+)
+
+type DiffReportOptions struct {
+	Format DiffReportFormat
+	// SetName, Context and Namespace are shown in the header of the report.
+	SetName   string
+	Context   string
+	Namespace string
+	// ContextLines is the number of unchanged lines shown around changes.
+	ContextLines int
+	// DiffOptions normalize the objects the same way as for
+	// AddObjectResultsToDiffer.
+	DiffOptions AddObjectResultsToDifferOptions
+}
+
+type diffReport struct {
+	SetName   string
+	Context   string
+	Namespace string
+	Totals    DiffStatTotals
+	Sections  []diffReportSection
+}
+
+type diffReportSection struct {
+	Title string
+	Stat  ObjectDiffStat
+	Diff  string
+}
+
+// WriteDiffReport writes a self-contained report of the changes of results,
+// e.g. those of an ApplyResult, with a collapsible section holding the diff of
+// each changed object.
+func WriteDiffReport(w io.Writer, results []ObjectResult, opt DiffReportOptions) error {
+	diffs, err := diffObjectResultsYAML(results, opt.DiffOptions)
+	if err != nil {
+		return err
+	}
+
+	report := diffReport{
+		SetName:   opt.SetName,
+		Context:   opt.Context,
+		Namespace: opt.Namespace,
+	}
+	for _, diff := range diffs {
+		switch diff.stat.Action {
+		case DiffActionCreate:
+			report.Totals.Create++
+		case DiffActionDelete:
+			report.Totals.Delete++
+		case DiffActionUnchanged:
+			report.Totals.Unchanged++
+			continue
+		default:
+			report.Totals.Update++
+		}
+		report.Totals.Added += diff.stat.Added
+		report.Totals.Removed += diff.stat.Removed
+
+		obj := diff.result.Live
+		if obj == nil {
+			obj = diff.result.Updated
+		}
+		var buf bytes.Buffer
+		if _, err := diffutil.Unified(&buf, "live", "updated", diff.live, diff.updated, diffutil.UnifiedOptions{
+			Context: opt.ContextLines,
+		}); err != nil {
+			return err
+		}
+		report.Sections = append(report.Sections, diffReportSection{
+			Title: describeObject(string(diff.stat.Action), obj),
+			Stat:  diff.stat,
+			Diff:  buf.String(),
+		})
+	}
+
+	switch opt.Format {
+	case DiffReportMarkdown:
+		_, err := io.WriteString(w, markdownDiffReport(report))
+		return err
+	case DiffReportHTML:
+		return htmlDiffReportTemplate.Execute(w, report)
+	default:
+		return fmt.Errorf("unknown diff report format %q", opt.Format)
+	}
+}
+
+func (r diffReport) summary() string {
+	return fmt.Sprintf("%d to create, %d to update, %d to delete, %d unchanged, %d lines added, %d lines removed",
+		r.Totals.Create, r.Totals.Update, r.Totals.Delete, r.Totals.Unchanged, r.Totals.Added, r.Totals.Removed)
+}
+
+func markdownDiffReport(report diffReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Config set %s\n\n", template.HTMLEscapeString(report.SetName))
+	sb.WriteString("| | |\n| --- | --- |\n")
+	fmt.Fprintf(&sb, "| Context | %s |\n", markdownTableCell(report.Context))
+	fmt.Fprintf(&sb, "| Namespace | %s |\n", markdownTableCell(report.Namespace))
+	fmt.Fprintf(&sb, "| Changes | %s |\n", report.summary())
+	for _, section := range report.Sections {
+		// the fence must be longer than any backtick run in the diff
+		fence := "```"
+		for strings.Contains(section.Diff, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&sb, "\n<details>\n<summary>%s, +%d -%d</summary>\n\n", template.HTMLEscapeString(section.Title), section.Stat.Added, section.Stat.Removed)
+		fmt.Fprintf(&sb, "%sdiff\n%s%s\n\n</details>\n", fence, section.Diff, fence)
+	}
+	return sb.String()
+}
+
+// markdownTableCell escapes s for a cell of a Markdown table, which renders
+// HTML and ends at pipes.
+func markdownTableCell(s string) string {
+	return strings.ReplaceAll(template.HTMLEscapeString(s), "|", "\\|")
+}
+
+var yamlKey = regexp.MustCompile(`^([ -]*)([^\s:#'"][^:#]*|'[^']*'|"[^"]*"):(\s|$)`)
+
+// highlightDiffLine renders a line of a unified diff of YAML documents as
+// HTML, with the changed lines and the keys highlighted.
+func highlightDiffLine(line string) template.HTML {
+	class := "line"
+	content := true
+	switch {
+	case strings.HasPrefix(line, "@@"):
+		class, content = "line hunk", false
+	case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		class, content = "line file", false
+	case strings.HasPrefix(line, "+"):
+		class = "line add"
+	case strings.HasPrefix(line, "-"):
+		class = "line del"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<span class="` + class + `">`)
+	var m []int
+	if content && line != "" {
+		// keys follow the diff prefix of the line
+		m = yamlKey.FindStringSubmatchIndex(line[1:])
+	}
+	if m != nil {
+		keyStart, keyEnd := m[4]+1, m[5]+1
+		sb.WriteString(template.HTMLEscapeString(line[:keyStart]))
+		sb.WriteString(`<span class="key">` + template.HTMLEscapeString(line[keyStart:keyEnd]) + `</span>`)
+		sb.WriteString(template.HTMLEscapeString(line[keyEnd:]))
+	} else {
+		sb.WriteString(template.HTMLEscapeString(line))
+	}
+	sb.WriteString(`</span>`)
+	return template.HTML(sb.String())
+}
+
+var htmlDiffReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"summary": diffReport.summary,
+	"lines": func(s string) []string {
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	},
+	"highlight": highlightDiffLine,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Config set {{.SetName}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #d0d7de; padding: 4px 12px; text-align: left; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: 0.5em 0; }
+summary { cursor: pointer; padding: 6px 12px; font-family: monospace; }
+.stat-add { color: #1a7f37; }
+.stat-del { color: #cf222e; }
+pre { margin: 0; padding: 8px 12px; overflow-x: auto; background: #f6f8fa; }
+.line { display: block; min-height: 1.2em; white-space: pre; }
+.add { background: #dafbe1; }
+.del { background: #ffebe9; }
+.hunk { color: #8250df; }
+.file { font-weight: bold; }
+.key { color: #0550ae; }
+</style>
+</head>
+<body>
+<h1>Config set {{.SetName}}</h1>
+<table>
+<tr><th>Context</th><td>{{.Context}}</td></tr>
+<tr><th>Namespace</th><td>{{.Namespace}}</td></tr>
+<tr><th>Changes</th><td>{{summary .}}</td></tr>
+</table>
+{{- range .Sections}}
+<details>
+<summary>{{.Title}} <span class="stat-add">+{{.Stat.Added}}</span> <span class="stat-del">-{{.Stat.Removed}}</span></summary>
+<pre>{{range lines .Diff}}{{highlight .}}{{end}}</pre>
+</details>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,204 @@
+package configset
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newConfigMap(name string, value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": name},
+		"data":       map[string]interface{}{"a": "1", "b": value, "c": "3"},
+	}}
+}
+
+// reportResults creates, updates, deletes and leaves unchanged a config map.
+var reportResults = []ObjectResult{
+	{Updated: newConfigMap("created", "x")},
+	{Live: newConfigMap("updated", "old"), Updated: newConfigMap("updated", "new")},
+	{Live: newConfigMap("deleted", "x")},
+	{Live: newConfigMap("unchanged", "x"), Updated: newConfigMap("unchanged", "x")},
+}
+
+func TestWriteDiffReportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiffReport(&buf, reportResults, DiffReportOptions{
+		Format:       DiffReportMarkdown,
+		SetName:      "app",
+		Context:      "prod",
+		Namespace:    "default",
+		ContextLines: 1,
+		DiffOptions:  CleanDiffOptions(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# Config set app\n" +
+		"\n" +
+		"| | |\n" +
+		"| --- | --- |\n" +
+		"| Context | prod |\n" +
+		"| Namespace | default |\n" +
+		"| Changes | 1 to create, 1 to update, 1 to delete, 1 unchanged, 10 lines added, 10 lines removed |\n" +
+		"\n" +
+		"<details>\n" +
+		"<summary>create: configmap/created (namespace default), +9 -0</summary>\n" +
+		"\n" +
+		"```diff\n" +
+		"--- live\n" +
+		"+++ updated\n" +
+		"@@ -0,0 +1,9 @@\n" +
+		"+apiVersion: v1\n" +
+		"+data:\n" +
+		"+  a: \"1\"\n" +
+		"+  b: x\n" +
+		"+  c: \"3\"\n" +
+		"+kind: ConfigMap\n" +
+		"+metadata:\n" +
+		"+  name: created\n" +
+		"+  namespace: default\n" +
+		"```\n" +
+		"\n" +
+		"</details>\n" +
+		"\n" +
+		"<details>\n" +
+		"<summary>update: configmap/updated (namespace default), +1 -1</summary>\n" +
+		"\n" +
+		"```diff\n" +
+		"--- live\n" +
+		"+++ updated\n" +
+		"@@ -3,3 +3,3 @@\n" +
+		"   a: \"1\"\n" +
+		"-  b: old\n" +
+		"+  b: new\n" +
+		"   c: \"3\"\n" +
+		"```\n" +
+		"\n" +
+		"</details>\n" +
+		"\n" +
+		"<details>\n" +
+		"<summary>delete: configmap/deleted (namespace default), +0 -9</summary>\n" +
+		"\n" +
+		"```diff\n" +
+		"--- live\n" +
+		"+++ updated\n" +
+		"@@ -1,9 +0,0 @@\n" +
+		"-apiVersion: v1\n" +
+		"-data:\n" +
+		"-  a: \"1\"\n" +
+		"-  b: x\n" +
+		"-  c: \"3\"\n" +
+		"-kind: ConfigMap\n" +
+		"-metadata:\n" +
+		"-  name: deleted\n" +
+		"-  namespace: default\n" +
+		"```\n" +
+		"\n" +
+		"</details>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteDiffReportHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiffReport(&buf, reportResults, DiffReportOptions{
+		Format:       DiffReportHTML,
+		SetName:      "app",
+		Context:      "prod",
+		Namespace:    "default",
+		ContextLines: 1,
+		DiffOptions:  CleanDiffOptions(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"<title>Config set app</title>",
+		"<tr><th>Context</th><td>prod</td></tr>",
+		"<tr><th>Changes</th><td>1 to create, 1 to update, 1 to delete, 1 unchanged, 10 lines added, 10 lines removed</td></tr>",
+		`<summary>create: configmap/created (namespace default) <span class="stat-add">+9</span> <span class="stat-del">-0</span></summary>`,
+		`<summary>delete: configmap/deleted (namespace default) <span class="stat-add">+0</span> <span class="stat-del">-9</span></summary>`,
+		`<span class="line hunk">@@ -3,3 +3,3 @@</span>` +
+			`<span class="line">   <span class="key">a</span>: &#34;1&#34;</span>` +
+			`<span class="line del">-  <span class="key">b</span>: old</span>` +
+			`<span class="line add">+  <span class="key">b</span>: new</span>` +
+			`<span class="line">   <span class="key">c</span>: &#34;3&#34;</span></pre>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report doesn't contain %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "configmap/unchanged") {
+		t.Errorf("report has a section for the unchanged object:\n%s", got)
+	}
+}
+
+func TestWriteDiffReportEscaping(t *testing.T) {
+	results := []ObjectResult{
+		{Live: newConfigMap("web", "<script>old</script>"), Updated: newConfigMap("web", `<b>new</b> & "quoted"`)},
+	}
+	opt := DiffReportOptions{
+		SetName:      "<app>",
+		Context:      "a|b<i>",
+		Namespace:    "default",
+		ContextLines: 0,
+		DiffOptions:  CleanDiffOptions(),
+	}
+
+	for _, tt := range []struct {
+		format DiffReportFormat
+		want   []string
+	}{
+		{
+			format: DiffReportHTML,
+			want: []string{
+				"<title>Config set &lt;app&gt;</title>",
+				"<td>a|b&lt;i&gt;</td>",
+				"&lt;script&gt;old&lt;/script&gt;",
+				"&lt;b&gt;new&lt;/b&gt; &amp; &#34;quoted&#34;",
+			},
+		},
+		{
+			format: DiffReportMarkdown,
+			want: []string{
+				"# Config set &lt;app&gt;\n",
+				`| Context | a\|b&lt;i&gt; |`,
+			},
+		},
+	} {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			opt.Format = tt.format
+			if err := WriteDiffReport(&buf, results, opt); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("report doesn't contain %s:\n%s", want, got)
+				}
+			}
+			if tt.format == DiffReportHTML {
+				for _, raw := range []string{"<script>", "<b>", "<app>", "<i>"} {
+					if strings.Contains(got, raw) {
+						t.Errorf("report contains unescaped %s:\n%s", raw, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWriteDiffReportUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiffReport(&buf, reportResults, DiffReportOptions{Format: "pdf"}); err == nil {
+		t.Error("WriteDiffReport accepted an unknown format")
+	}
+}
@@ -0,0 +1,85 @@
+package configset
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetInfoSchemaVersion is the schema version of set infos written by this
+// version of configset. Set infos written before schema versioning have
+// schema version 0.
+const SetInfoSchemaVersion = 1
+
+// setInfoMigrations[i] upgrades a set info from schema version i to i+1.
+var setInfoMigrations = []func(info *SetInfo) error{
+	// 0 -> 1: the schema is unchanged, only the version is recorded
+	func(info *SetInfo) error { return nil },
+}
+
+// MigrateSetInfo upgrades info to SetInfoSchemaVersion in place. It fails on
+// set infos written by a newer version of configset.
+func MigrateSetInfo(info *SetInfo) error {
+	if info.SchemaVersion > SetInfoSchemaVersion {
+		return fmt.Errorf("set info %s has schema version %d, newer than the supported %d", info.Name, info.SchemaVersion, SetInfoSchemaVersion)
+	}
+	for info.SchemaVersion < SetInfoSchemaVersion {
+		if err := setInfoMigrations[info.SchemaVersion](info); err != nil {
+			return fmt.Errorf("failed to migrate set info %s from schema version %d: %w", info.Name, info.SchemaVersion, err)
+		}
+		info.SchemaVersion++
+	}
+	return nil
+}
+
+type MigrateSchemaOptions struct {
+	DryRun bool
+	// BackupFunc, if set, is called with the payload of each set info as
+	// originally stored before it gets rewritten.
+	BackupFunc func(name string, payload []byte) error
+}
+
+type SchemaMigrationResult struct {
+	Name        string
+	FromVersion int
+	ToVersion   int
+}
+
+// MigrateSetInfoSchemas rewrites all set infos of store that have an older
+// schema version. The stores of this package upgrade set infos when reading
+// them, while set infos read from other stores are upgraded here.
+func MigrateSetInfoSchemas(ctx context.Context, store SetInfoStore, opt MigrateSchemaOptions) ([]SchemaMigrationResult, error) {
+	infos, err := store.ListSetInfos(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list set infos: %w", err)
+	}
+	results := make([]SchemaMigrationResult, 0, len(infos))
+	for _, info := range infos {
+		res := SchemaMigrationResult{
+			Name:        info.Name,
+			FromVersion: info.storedSchemaVersion,
+		}
+		payload := info.storedPayload
+		if payload == nil {
+			// read by a store of another package, which returns set infos as
+			// stored without migrating them
+			res.FromVersion = info.SchemaVersion
+			payload = info.toJSON()
+			if err := MigrateSetInfo(info); err != nil {
+				return results, err
+			}
+		}
+		res.ToVersion = info.SchemaVersion
+		if res.FromVersion < res.ToVersion && !opt.DryRun {
+			if opt.BackupFunc != nil {
+				if err := opt.BackupFunc(info.Name, payload); err != nil {
+					return results, fmt.Errorf("failed to back up set info %s: %w", info.Name, err)
+				}
+			}
+			if err := store.UpdateSetInfo(ctx, info.Name, info); err != nil {
+				return results, fmt.Errorf("failed to update set info %s: %w", info.Name, err)
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
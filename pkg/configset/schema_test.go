@@ -0,0 +1,66 @@
+package configset_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+	"github.com/wxdao/configset/pkg/configsettest"
+)
+
+func TestMigrateSetInfoSchemas(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]configset.SetInfoStore{
+		"memory": configsettest.NewMemorySetInfoStore(),
+	}
+	file, err := configset.NewFileSetInfoStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores["file"] = file
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := store.CreateSetInfo(ctx, "old", &configset.SetInfo{Name: "old"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateSetInfo(ctx, "current", &configset.SetInfo{Name: "current", SchemaVersion: configset.SetInfoSchemaVersion}); err != nil {
+				t.Fatal(err)
+			}
+
+			backups := map[string]bool{}
+			migrate := func() map[string]int {
+				results, err := configset.MigrateSetInfoSchemas(ctx, store, configset.MigrateSchemaOptions{
+					BackupFunc: func(name string, payload []byte) error {
+						backups[name] = true
+						return nil
+					},
+				})
+				if err != nil {
+					t.Fatalf("MigrateSetInfoSchemas: %v", err)
+				}
+				from := map[string]int{}
+				for _, res := range results {
+					if res.ToVersion != configset.SetInfoSchemaVersion {
+						t.Errorf("set info %s migrated to schema version %d, want %d", res.Name, res.ToVersion, configset.SetInfoSchemaVersion)
+					}
+					from[res.Name] = res.FromVersion
+				}
+				return from
+			}
+
+			from := migrate()
+			if from["old"] != 0 || from["current"] != configset.SetInfoSchemaVersion {
+				t.Errorf("got schema versions %v before migration", from)
+			}
+			if !backups["old"] || backups["current"] {
+				t.Errorf("got backups of %v, want old only", backups)
+			}
+
+			from = migrate()
+			if from["old"] != configset.SetInfoSchemaVersion || from["current"] != configset.SetInfoSchemaVersion {
+				t.Errorf("got schema versions %v after migration", from)
+			}
+		})
+	}
+}
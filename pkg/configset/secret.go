@@ -1,9 +1,17 @@
 package configset
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -13,46 +21,108 @@ import (
 )
 
 const (
-	DefaultSetInfoSecretPrefix            = "configset.v1."
-	DefaultSetInfoSecretDataKey           = "data"
-	DefaultSetInfoSecretFieldOwner        = "configset/secret-store"
-	DefaultSetInfoSecretLockAnnotationKey = "configset/lock-id"
-	DefaultSetInfoSecretIsSetInfoLabelKey = "configset/is-set-info"
+	DefaultSetInfoSecretPrefix                = "configset.v1."
+	DefaultSetInfoSecretShardPrefix           = "configset-shard.v1."
+	DefaultSetInfoSecretDataKey               = "data"
+	DefaultSetInfoSecretFieldOwner            = "configset/secret-store"
+	DefaultSetInfoSecretLockAnnotationKey     = "configset/lock-id"
+	DefaultSetInfoSecretIsSetInfoLabelKey     = "configset/is-set-info"
+	DefaultSetInfoSecretIsShardLabelKey       = "configset/is-set-info-shard"
+	DefaultSetInfoSecretFormatAnnotationKey   = "configset/format"
+	DefaultSetInfoSecretShardsAnnotationKey   = "configset/shards"
+	DefaultSetInfoSecretShardOfAnnotationKey  = "configset/shard-of"
+	DefaultSetInfoSecretChecksumAnnotationKey = "configset/checksum"
+	DefaultSetInfoSecretChunkSize             = 512 * 1024
+	defaultSetInfoSecretReadAttempts          = 3
+	setInfoFormatJSON                         = "json"
+	setInfoFormatGzip                         = "gzip"
 )
 
+var errInconsistentShards = errors.New("inconsistent set info shards")
+
+// SecretSetInfoStore stores each set info as gzipped JSON in a Secret named
+// after the set. Set infos too large for a single Secret are split into
+// chunks, the first one being kept in the head Secret and the others in shard
+// Secrets named with the chunk index as suffix. Shards are named
+// configset-shard.v1.<name>.<n> rather than configset.v1.<name>.<n>, which
+// would clash with the head Secret of a set named <name>.<n>. All Secrets of
+// a set carry the checksum of the whole payload, so that readers can detect a
+// set info being replaced concurrently. Secrets without the format annotation
+// hold plain JSON as written by earlier versions.
 type SecretSetInfoStore struct {
 	kube              crclient.Client
 	namespace         string
 	namePrefix        string
+	shardNamePrefix   string
 	dataKey           string
 	fieldOwner        string
 	lockAnnoKey       string
 	isSetInfoLabelKey string
+	isShardLabelKey   string
+	formatAnnoKey     string
+	shardsAnnoKey     string
+	shardOfAnnoKey    string
+	checksumAnnoKey   string
+	chunkSize         int
 }
 
 var _ SetInfoStore = &SecretSetInfoStore{}
+var _ AllNamespacesSetInfoLister = &SecretSetInfoStore{}
 
 func NewSecretSetInfoStore(kubeClient crclient.Client, namespace string) (*SecretSetInfoStore, error) {
 	return &SecretSetInfoStore{
 		kube:              kubeClient,
 		namespace:         namespace,
 		namePrefix:        DefaultSetInfoSecretPrefix,
+		shardNamePrefix:   DefaultSetInfoSecretShardPrefix,
 		dataKey:           DefaultSetInfoSecretDataKey,
 		fieldOwner:        DefaultSetInfoSecretFieldOwner,
 		lockAnnoKey:       DefaultSetInfoSecretLockAnnotationKey,
 		isSetInfoLabelKey: DefaultSetInfoSecretIsSetInfoLabelKey,
+		isShardLabelKey:   DefaultSetInfoSecretIsShardLabelKey,
+		formatAnnoKey:     DefaultSetInfoSecretFormatAnnotationKey,
+		shardsAnnoKey:     DefaultSetInfoSecretShardsAnnotationKey,
+		shardOfAnnoKey:    DefaultSetInfoSecretShardOfAnnotationKey,
+		checksumAnnoKey:   DefaultSetInfoSecretChecksumAnnotationKey,
+		chunkSize:         DefaultSetInfoSecretChunkSize,
 	}, nil
 }
 
 func (s *SecretSetInfoStore) GetSetInfo(ctx context.Context, name string) (*SetInfo, error) {
-	var secret corev1.Secret
-	if err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.namePrefix + name}, &secret); err != nil {
-		if apierrors.IsNotFound(err) {
+	return s.getSetInfo(ctx, s.namespace, name)
+}
+
+func (s *SecretSetInfoStore) getSetInfo(ctx context.Context, namespace string, name string) (*SetInfo, error) {
+	var err error
+	for i := 0; i < defaultSetInfoSecretReadAttempts; i++ {
+		var head corev1.Secret
+		if err := s.kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: s.namePrefix + name}, &head); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to get secret: %w", err)
+		}
+		if head.Labels[s.isSetInfoLabelKey] == "" {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to get secret: %w", err)
+
+		var info *SetInfo
+		info, err = s.setInfoFromSecrets(&head, func(index int) (*corev1.Secret, error) {
+			var shard corev1.Secret
+			if err := s.kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: s.shardName(name, index)}, &shard); err != nil {
+				if apierrors.IsNotFound(err) {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("failed to get secret: %w", err)
+			}
+			return &shard, nil
+		})
+		if !errors.Is(err, errInconsistentShards) {
+			return info, err
+		}
+		// the set info is being replaced, read it again
 	}
-	return setInfoFromJSON(secret.Data[s.dataKey])
+	return nil, err
 }
 
 func (s *SecretSetInfoStore) ListSetInfos(ctx context.Context) ([]*SetInfo, error) {
@@ -60,38 +130,135 @@ func (s *SecretSetInfoStore) ListSetInfos(ctx context.Context) ([]*SetInfo, erro
 	if err := s.kube.List(ctx, &secretList, crclient.InNamespace(s.namespace), crclient.HasLabels{s.isSetInfoLabelKey}); err != nil {
 		return nil, fmt.Errorf("failed to list secrets: %w", err)
 	}
-	infos := make([]*SetInfo, 0, len(secretList.Items))
-	for _, secret := range secretList.Items {
-		info, err := setInfoFromJSON(secret.Data[s.dataKey])
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse secret %s: %w", secret.Name, err)
-		}
-		infos = append(infos, info)
+	var shardList corev1.SecretList
+	if err := s.kube.List(ctx, &shardList, crclient.InNamespace(s.namespace), crclient.HasLabels{s.isShardLabelKey}); err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	namespaced, err := s.setInfosFromSecrets(ctx, secretList.Items, shardList.Items)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*SetInfo, 0, len(namespaced))
+	for _, info := range namespaced {
+		infos = append(infos, info.SetInfo)
 	}
 	return infos, nil
 }
 
+func (s *SecretSetInfoStore) ListAllNamespacesSetInfos(ctx context.Context) ([]NamespacedSetInfo, []string, error) {
+	var heads, shards []corev1.Secret
+	skipped, err := listInAllNamespaces(ctx, s.kube, "secrets", func(namespace string) error {
+		var secretList, shardList corev1.SecretList
+		if err := s.kube.List(ctx, &secretList, crclient.InNamespace(namespace), crclient.HasLabels{s.isSetInfoLabelKey}); err != nil {
+			return err
+		}
+		if err := s.kube.List(ctx, &shardList, crclient.InNamespace(namespace), crclient.HasLabels{s.isShardLabelKey}); err != nil {
+			return err
+		}
+		heads = append(heads, secretList.Items...)
+		shards = append(shards, shardList.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	infos, err := s.setInfosFromSecrets(ctx, heads, shards)
+	if err != nil {
+		return nil, nil, err
+	}
+	return infos, skipped, nil
+}
+
 func (s *SecretSetInfoStore) CreateSetInfo(ctx context.Context, name string, info *SetInfo) error {
-	secret := &corev1.Secret{
+	var head corev1.Secret
+	err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.namePrefix + name}, &head)
+	if err == nil {
+		return fmt.Errorf("failed to create secret: %w", apierrors.NewAlreadyExists(corev1.Resource("secrets"), head.Name))
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	chunks, format, checksum, err := s.encode(info)
+	if err != nil {
+		return err
+	}
+	if err := s.applyShards(ctx, name, chunks, checksum); err != nil {
+		return err
+	}
+	secret := s.headSecret(name, chunks, format, checksum)
+	if err := s.kube.Create(ctx, &secret, crclient.FieldOwner(s.fieldOwner)); err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+	return nil
+}
+
+func (s *SecretSetInfoStore) UpdateSetInfo(ctx context.Context, name string, info *SetInfo) error {
+	oldShards, err := s.shardCount(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	chunks, format, checksum, err := s.encode(info)
+	if err != nil {
+		return err
+	}
+	// shards are written before the head so that the head never refers to
+	// missing chunks, readers catch mismatching ones by the checksum
+	if err := s.applyShards(ctx, name, chunks, checksum); err != nil {
+		return err
+	}
+	secret := s.headSecret(name, chunks, format, checksum)
+	if err := s.kube.Patch(ctx, &secret, crclient.Apply, crclient.FieldOwner(s.fieldOwner), crclient.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+	return s.deleteShards(ctx, name, len(chunks), oldShards)
+}
+
+func (s *SecretSetInfoStore) DeleteSetInfo(ctx context.Context, name string) error {
+	shards, err := s.shardCount(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := crclient.IgnoreNotFound(s.kube.Delete(ctx, &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.namespace,
 			Name:      s.namePrefix + name,
-			Labels: map[string]string{
-				s.isSetInfoLabelKey: "true",
-			},
-		},
-		Data: map[string][]byte{
-			s.dataKey: info.toJSON(),
 		},
+	})); err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
 	}
-	if err := s.kube.Create(ctx, secret, crclient.FieldOwner(s.fieldOwner)); err != nil {
-		return fmt.Errorf("failed to create secret: %w", err)
+	return s.deleteShards(ctx, name, 1, shards)
+}
+
+func (s *SecretSetInfoStore) shardName(name string, index int) string {
+	return s.shardNamePrefix + name + "." + strconv.Itoa(index)
+}
+
+// shardCount returns the number of chunks, including the head one, the
+// current set info is split into, or 0 if it doesn't exist. It refuses to
+// count a head Secret that isn't a set info, so that it's never overwritten
+// or deleted.
+func (s *SecretSetInfoStore) shardCount(ctx context.Context, name string) (int, error) {
+	var head corev1.Secret
+	if err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.namePrefix + name}, &head); err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get secret: %w", err)
 	}
-	return nil
+	if head.Labels[s.isSetInfoLabelKey] == "" {
+		return 0, fmt.Errorf("secret %s already exists and is not a set info", head.Name)
+	}
+	n, err := strconv.Atoi(head.Annotations[s.shardsAnnoKey])
+	if err != nil || n < 1 {
+		n = 1
+	}
+	return n, nil
 }
 
-func (s *SecretSetInfoStore) UpdateSetInfo(ctx context.Context, name string, info *SetInfo) error {
-	secret := corev1.Secret{
+func (s *SecretSetInfoStore) headSecret(name string, chunks [][]byte, format string, checksum string) corev1.Secret {
+	return corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "Secret",
@@ -102,33 +269,176 @@ func (s *SecretSetInfoStore) UpdateSetInfo(ctx context.Context, name string, inf
 			Labels: map[string]string{
 				s.isSetInfoLabelKey: "true",
 			},
+			Annotations: map[string]string{
+				s.formatAnnoKey:   format,
+				s.shardsAnnoKey:   strconv.Itoa(len(chunks)),
+				s.checksumAnnoKey: checksum,
+			},
 		},
-		Data: map[string][]byte{s.dataKey: info.toJSON()},
+		Data: map[string][]byte{s.dataKey: chunks[0]},
 	}
-	secret.GetObjectKind()
-	if err := s.kube.Patch(ctx, &secret, crclient.Apply, crclient.FieldOwner(s.fieldOwner), crclient.ForceOwnership); err != nil {
-		return fmt.Errorf("failed to update secret: %w", err)
+}
+
+// applyShards writes the chunks but the head one to shards, refusing to take
+// over Secrets that aren't shards of the set.
+func (s *SecretSetInfoStore) applyShards(ctx context.Context, name string, chunks [][]byte, checksum string) error {
+	for i := 1; i < len(chunks); i++ {
+		var existing corev1.Secret
+		err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.shardName(name, i)}, &existing)
+		if err == nil && (existing.Labels[s.isShardLabelKey] == "" || existing.Annotations[s.shardOfAnnoKey] != name) {
+			return fmt.Errorf("secret %s already exists and is not a shard of set %s", existing.Name, name)
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get secret: %w", err)
+		}
+
+		shard := corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: s.namespace,
+				Name:      s.shardName(name, i),
+				Labels: map[string]string{
+					s.isShardLabelKey: "true",
+				},
+				Annotations: map[string]string{
+					s.shardOfAnnoKey:  name,
+					s.checksumAnnoKey: checksum,
+				},
+			},
+			Data: map[string][]byte{s.dataKey: chunks[i]},
+		}
+		if err := s.kube.Patch(ctx, &shard, crclient.Apply, crclient.FieldOwner(s.fieldOwner), crclient.ForceOwnership); err != nil {
+			return fmt.Errorf("failed to update secret: %w", err)
+		}
 	}
 	return nil
 }
 
-func (s *SecretSetInfoStore) DeleteSetInfo(ctx context.Context, name string) error {
-	if err := crclient.IgnoreNotFound(s.kube.Delete(ctx, &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: s.namespace,
-			Name:      s.namePrefix + name,
-		},
-	})); err != nil {
-		return fmt.Errorf("failed to delete secret: %w", err)
+// deleteShards deletes the shards with index in [from, to).
+func (s *SecretSetInfoStore) deleteShards(ctx context.Context, name string, from int, to int) error {
+	for i := from; i < to; i++ {
+		if err := crclient.IgnoreNotFound(s.kube.Delete(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: s.namespace,
+				Name:      s.shardName(name, i),
+			},
+		})); err != nil {
+			return fmt.Errorf("failed to delete secret: %w", err)
+		}
 	}
 	return nil
 }
 
+func (s *SecretSetInfoStore) encode(info *SetInfo) ([][]byte, string, string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(info.toJSON()); err != nil {
+		return nil, "", "", fmt.Errorf("failed to compress set info: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", "", fmt.Errorf("failed to compress set info: %w", err)
+	}
+	payload := buf.Bytes()
+	sum := sha256.Sum256(payload)
+
+	chunks := [][]byte{}
+	for len(payload) > s.chunkSize {
+		chunks = append(chunks, payload[:s.chunkSize])
+		payload = payload[s.chunkSize:]
+	}
+	chunks = append(chunks, payload)
+	return chunks, setInfoFormatGzip, hex.EncodeToString(sum[:]), nil
+}
+
+// setInfoFromSecrets decodes the set info of head, getting its shards with
+// getShard, which returns nil if the shard doesn't exist.
+func (s *SecretSetInfoStore) setInfoFromSecrets(head *corev1.Secret, getShard func(index int) (*corev1.Secret, error)) (*SetInfo, error) {
+	format := head.Annotations[s.formatAnnoKey]
+	if format == "" {
+		return setInfoFromJSON(head.Data[s.dataKey])
+	}
+
+	n, err := strconv.Atoi(head.Annotations[s.shardsAnnoKey])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid shard count %q", head.Annotations[s.shardsAnnoKey])
+	}
+	checksum := head.Annotations[s.checksumAnnoKey]
+	payload := append([]byte{}, head.Data[s.dataKey]...)
+	for i := 1; i < n; i++ {
+		shard, err := getShard(i)
+		if err != nil {
+			return nil, err
+		}
+		if shard == nil || shard.Annotations[s.checksumAnnoKey] != checksum {
+			return nil, errInconsistentShards
+		}
+		payload = append(payload, shard.Data[s.dataKey]...)
+	}
+	sum := sha256.Sum256(payload)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return nil, errInconsistentShards
+	}
+
+	switch format {
+	case setInfoFormatJSON:
+		return setInfoFromJSON(payload)
+	case setInfoFormatGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress set info: %w", err)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress set info: %w", err)
+		}
+		return setInfoFromJSON(b)
+	default:
+		return nil, fmt.Errorf("unknown set info format %q", format)
+	}
+}
+
+// setInfosFromSecrets decodes the set infos of heads with shards from the
+// given list, falling back to reading them again if being replaced.
+func (s *SecretSetInfoStore) setInfosFromSecrets(ctx context.Context, heads []corev1.Secret, shards []corev1.Secret) ([]NamespacedSetInfo, error) {
+	shardsByKey := map[types.NamespacedName]*corev1.Secret{}
+	for i := range shards {
+		shardsByKey[types.NamespacedName{Namespace: shards[i].Namespace, Name: shards[i].Name}] = &shards[i]
+	}
+	infos := make([]NamespacedSetInfo, 0, len(heads))
+	for i := range heads {
+		head := &heads[i]
+		name := strings.TrimPrefix(head.Name, s.namePrefix)
+		info, err := s.setInfoFromSecrets(head, func(index int) (*corev1.Secret, error) {
+			return shardsByKey[types.NamespacedName{Namespace: head.Namespace, Name: s.shardName(name, index)}], nil
+		})
+		if errors.Is(err, errInconsistentShards) {
+			info, err = s.getSetInfo(ctx, head.Namespace, name)
+			if err == nil && info == nil {
+				// deleted in the meantime
+				continue
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse secret %s/%s: %w", head.Namespace, head.Name, err)
+		}
+		infos = append(infos, NamespacedSetInfo{Namespace: head.Namespace, SetInfo: info})
+	}
+	return infos, nil
+}
+
 func setInfoFromJSON(b []byte) (*SetInfo, error) {
 	var info SetInfo
 	if err := json.Unmarshal(b, &info); err != nil {
 		return nil, err
 	}
+	info.storedSchemaVersion = info.SchemaVersion
+	info.storedPayload = b
+	if err := MigrateSetInfo(&info); err != nil {
+		return nil, err
+	}
 	return &info, nil
 }
 
@@ -0,0 +1,266 @@
+package configset_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newShardedSecretStore returns a secret store splitting set infos into
+// chunks of 256 bytes.
+func newShardedSecretStore(t *testing.T, kube crclient.Client) *configset.SecretSetInfoStore {
+	store, err := configset.NewSecretSetInfoStore(kube, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetChunkSize(256)
+	return store
+}
+
+// largeSetInfo returns a set info of n resources with names that don't
+// compress well.
+func largeSetInfo(name string, n int) *configset.SetInfo {
+	info := &configset.SetInfo{Name: name}
+	for i := 0; i < n; i++ {
+		sum := sha256.Sum256([]byte(strconv.Itoa(i)))
+		info.Resources = append(info.Resources, configset.ResourceInfo{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  "default",
+			Name:       hex.EncodeToString(sum[:]),
+		})
+	}
+	return info
+}
+
+// secretNames returns the sorted names of the Secrets in the default
+// namespace.
+func secretNames(t *testing.T, kube crclient.Client) []string {
+	t.Helper()
+	var list corev1.SecretList
+	if err := kube.List(context.Background(), &list, crclient.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, secret := range list.Items {
+		names = append(names, secret.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setSecretNames returns the sorted names of the head and shard Secrets of a
+// set info split into n chunks.
+func setSecretNames(name string, n int) []string {
+	names := []string{"configset.v1." + name}
+	for i := 1; i < n; i++ {
+		names = append(names, fmt.Sprintf("configset-shard.v1.%s.%d", name, i))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertSetInfoResources(t *testing.T, store configset.SetInfoStore, want *configset.SetInfo) {
+	t.Helper()
+	info, err := store.GetSetInfo(context.Background(), want.Name)
+	if err != nil {
+		t.Fatalf("GetSetInfo: %v", err)
+	}
+	if info == nil || !reflect.DeepEqual(info.Resources, want.Resources) {
+		t.Fatalf("set info %s doesn't have the written resources", want.Name)
+	}
+}
+
+func TestSecretSetInfoStoreShards(t *testing.T) {
+	ctx := context.Background()
+	kube := newFakeKubeClient()
+	store := newShardedSecretStore(t, kube)
+
+	large := largeSetInfo("a", 100)
+	if err := store.UpdateSetInfo(ctx, "a", large); err != nil {
+		t.Fatalf("UpdateSetInfo: %v", err)
+	}
+	names := secretNames(t, kube)
+	if len(names) < 4 {
+		t.Fatalf("got secrets %v, want a head and several shards", names)
+	}
+	shards := len(names)
+	if want := setSecretNames("a", shards); !reflect.DeepEqual(names, want) {
+		t.Fatalf("got secrets %v, want %v", names, want)
+	}
+	assertSetInfoResources(t, store, large)
+	infos, err := store.ListSetInfos(ctx)
+	if err != nil {
+		t.Fatalf("ListSetInfos: %v", err)
+	}
+	if len(infos) != 1 || !reflect.DeepEqual(infos[0].Resources, large.Resources) {
+		t.Fatalf("ListSetInfos doesn't return the written set info")
+	}
+
+	// shrinking deletes the stale shards
+	smaller := largeSetInfo("a", 30)
+	if err := store.UpdateSetInfo(ctx, "a", smaller); err != nil {
+		t.Fatalf("UpdateSetInfo: %v", err)
+	}
+	names = secretNames(t, kube)
+	if len(names) < 2 || len(names) >= shards {
+		t.Fatalf("got secrets %v, want fewer shards than %d", names, shards-1)
+	}
+	if want := setSecretNames("a", len(names)); !reflect.DeepEqual(names, want) {
+		t.Fatalf("got secrets %v, want %v", names, want)
+	}
+	assertSetInfoResources(t, store, smaller)
+
+	small := &configset.SetInfo{Name: "a"}
+	if err := store.UpdateSetInfo(ctx, "a", small); err != nil {
+		t.Fatalf("UpdateSetInfo: %v", err)
+	}
+	if names := secretNames(t, kube); !reflect.DeepEqual(names, []string{"configset.v1.a"}) {
+		t.Fatalf("got secrets %v, want the head only", names)
+	}
+	assertSetInfoResources(t, store, small)
+
+	// deleting removes every shard
+	if err := store.UpdateSetInfo(ctx, "a", large); err != nil {
+		t.Fatalf("UpdateSetInfo: %v", err)
+	}
+	if err := store.DeleteSetInfo(ctx, "a"); err != nil {
+		t.Fatalf("DeleteSetInfo: %v", err)
+	}
+	if names := secretNames(t, kube); len(names) != 0 {
+		t.Fatalf("got secrets %v after deleting, want none", names)
+	}
+}
+
+func TestSecretSetInfoStoreChecksumMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(shard *corev1.Secret)
+	}{
+		{
+			name: "shard of another write",
+			modify: func(shard *corev1.Secret) {
+				shard.Annotations[configset.DefaultSetInfoSecretChecksumAnnotationKey] = "other"
+			},
+		},
+		{
+			name: "corrupted shard",
+			modify: func(shard *corev1.Secret) {
+				shard.Data[configset.DefaultSetInfoSecretDataKey][0] ^= 0xff
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kube := newFakeKubeClient()
+			store := newShardedSecretStore(t, kube)
+			if err := store.UpdateSetInfo(ctx, "a", largeSetInfo("a", 100)); err != nil {
+				t.Fatalf("UpdateSetInfo: %v", err)
+			}
+
+			var shard corev1.Secret
+			if err := kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: "configset-shard.v1.a.1"}, &shard); err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(&shard)
+			if err := kube.Update(ctx, &shard); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := store.GetSetInfo(ctx, "a"); err == nil || !strings.Contains(err.Error(), "inconsistent") {
+				t.Errorf("GetSetInfo returned %v, want an inconsistent shards error", err)
+			}
+			if _, err := store.ListSetInfos(ctx); err == nil || !strings.Contains(err.Error(), "inconsistent") {
+				t.Errorf("ListSetInfos returned %v, want an inconsistent shards error", err)
+			}
+		})
+	}
+}
+
+func TestSecretSetInfoStoreLegacySecret(t *testing.T) {
+	ctx := context.Background()
+	kube := newFakeKubeClient()
+	store := newShardedSecretStore(t, kube)
+
+	// as written by versions before compression and sharding
+	legacy := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "configset.v1.a",
+			Labels:    map[string]string{configset.DefaultSetInfoSecretIsSetInfoLabelKey: "true"},
+		},
+		Data: map[string][]byte{
+			configset.DefaultSetInfoSecretDataKey: []byte(`{"name":"a","resources":[{"apiVersion":"v1","kind":"ConfigMap","namespace":"default","name":"x","uid":"uid-x"}]}`),
+		},
+	}
+	if err := kube.Create(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []configset.ResourceInfo{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "x", UID: "uid-x"}}
+	info, err := store.GetSetInfo(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetSetInfo: %v", err)
+	}
+	if info == nil || info.Name != "a" || !reflect.DeepEqual(info.Resources, want) {
+		t.Fatalf("GetSetInfo returned %+v, want the resources of the legacy secret", info)
+	}
+	infos, err := store.ListSetInfos(ctx)
+	if err != nil {
+		t.Fatalf("ListSetInfos: %v", err)
+	}
+	if len(infos) != 1 || !reflect.DeepEqual(infos[0].Resources, want) {
+		t.Fatalf("ListSetInfos doesn't return the legacy set info")
+	}
+}
+
+func TestSecretSetInfoStoreForeignSecrets(t *testing.T) {
+	ctx := context.Background()
+	kube := newFakeKubeClient()
+	store := newShardedSecretStore(t, kube)
+
+	foreign := func(name string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Data:       map[string][]byte{"key": []byte("value")},
+		}
+	}
+	for _, name := range []string{"configset.v1.a", "configset-shard.v1.b.1"} {
+		if err := kube.Create(ctx, foreign(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.UpdateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err == nil {
+		t.Error("UpdateSetInfo overwrote a foreign head secret")
+	}
+	if err := store.DeleteSetInfo(ctx, "a"); err == nil {
+		t.Error("DeleteSetInfo deleted a foreign head secret")
+	}
+	if err := store.UpdateSetInfo(ctx, "b", largeSetInfo("b", 100)); err == nil {
+		t.Error("UpdateSetInfo overwrote a foreign shard secret")
+	}
+
+	for _, name := range []string{"configset.v1.a", "configset-shard.v1.b.1"} {
+		var secret corev1.Secret
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &secret); err != nil {
+			t.Fatalf("failed to get secret %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(secret.Data, foreign(name).Data) || len(secret.Labels) != 0 {
+			t.Errorf("secret %s was modified", name)
+		}
+	}
+}
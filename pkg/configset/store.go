@@ -0,0 +1,158 @@
+package configset
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type CopySetInfosOptions struct {
+	// Names selects the sets to copy, all sets are copied if empty.
+	Names        []string
+	DryRun       bool
+	DeleteSource bool
+}
+
+type CopySetInfoResult struct {
+	Name string
+	// Overwritten tells whether the set already existed in the destination.
+	Overwritten bool
+	Verified    bool
+	Deleted     bool
+}
+
+// CopySetInfos copies set infos from src to dst, overwriting the ones already
+// in dst, and fails if both keep set infos in the same place. Each copy is
+// read back from dst and compared with the original, and if DeleteSource is
+// set, only verified set infos are deleted from src.
+func CopySetInfos(ctx context.Context, src SetInfoStore, dst SetInfoStore, opt CopySetInfosOptions) ([]CopySetInfoResult, error) {
+	if sameStore(src, dst) {
+		return nil, fmt.Errorf("source and destination stores are the same")
+	}
+
+	var infos []*SetInfo
+	if len(opt.Names) == 0 {
+		var err error
+		infos, err = src.ListSetInfos(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list set infos: %w", err)
+		}
+	} else {
+		for _, name := range opt.Names {
+			info, err := src.GetSetInfo(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get set info %s: %w", name, err)
+			}
+			if info == nil {
+				return nil, fmt.Errorf("set info %s not found", name)
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	results := make([]CopySetInfoResult, 0, len(infos))
+	for _, info := range infos {
+		res := CopySetInfoResult{Name: info.Name}
+
+		existing, err := dst.GetSetInfo(ctx, info.Name)
+		if err != nil {
+			return results, fmt.Errorf("failed to get set info %s from destination: %w", info.Name, err)
+		}
+		res.Overwritten = existing != nil
+		if opt.DryRun {
+			results = append(results, res)
+			continue
+		}
+
+		if err := dst.UpdateSetInfo(ctx, info.Name, info); err != nil {
+			return results, fmt.Errorf("failed to copy set info %s: %w", info.Name, err)
+		}
+		copied, err := dst.GetSetInfo(ctx, info.Name)
+		if err != nil {
+			return results, fmt.Errorf("failed to get set info %s from destination: %w", info.Name, err)
+		}
+		if copied == nil || !bytes.Equal(copied.toJSON(), info.toJSON()) {
+			return results, fmt.Errorf("set info %s in destination doesn't match the source", info.Name)
+		}
+		res.Verified = true
+
+		if opt.DeleteSource {
+			if err := src.DeleteSetInfo(ctx, info.Name); err != nil {
+				return results, fmt.Errorf("failed to delete set info %s from source: %w", info.Name, err)
+			}
+			res.Deleted = true
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// sameStore tells whether a and b keep set infos in the same place, where
+// copying a set info would overwrite itself and deleting the source would
+// delete the copy.
+func sameStore(a SetInfoStore, b SetInfoStore) bool {
+	switch a := a.(type) {
+	case *SecretSetInfoStore:
+		b, ok := b.(*SecretSetInfoStore)
+		return ok && a.kube == b.kube && a.namespace == b.namespace && a.namePrefix == b.namePrefix
+	case *ConfigMapSetInfoStore:
+		b, ok := b.(*ConfigMapSetInfoStore)
+		return ok && a.kube == b.kube && a.namespace == b.namespace && a.namePrefix == b.namePrefix
+	case *CRDSetInfoStore:
+		b, ok := b.(*CRDSetInfoStore)
+		return ok && a.kube == b.kube && a.namespace == b.namespace
+	case *FileSetInfoStore:
+		b, ok := b.(*FileSetInfoStore)
+		if !ok {
+			return false
+		}
+		if filepath.Clean(a.dir) == filepath.Clean(b.dir) {
+			return true
+		}
+		// the paths may differ by being relative or by symlinks
+		aInfo, aErr := os.Stat(a.dir)
+		bInfo, bErr := os.Stat(b.dir)
+		return aErr == nil && bErr == nil && os.SameFile(aInfo, bInfo)
+	default:
+		return reflect.TypeOf(a).Comparable() && a == b
+	}
+}
+
+// listInAllNamespaces calls list with an empty namespace to list the objects
+// of all namespaces at once, or if that's forbidden, with each namespace in
+// turn, skipping and returning the namespaces where it is forbidden too. list
+// must keep nothing of calls that fail. What names the listed objects in
+// errors, e.g. secrets.
+func listInAllNamespaces(ctx context.Context, kube crclient.Client, what string, list func(namespace string) error) ([]string, error) {
+	err := list("")
+	if err == nil {
+		return nil, nil
+	}
+	if !apierrors.IsForbidden(err) {
+		return nil, fmt.Errorf("failed to list %s: %w", what, err)
+	}
+
+	// not allowed to list cluster-wide, try each namespace instead
+	var nsList corev1.NamespaceList
+	if err := kube.List(ctx, &nsList); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	var skipped []string
+	for _, ns := range nsList.Items {
+		if err := list(ns.Name); err != nil {
+			if apierrors.IsForbidden(err) {
+				skipped = append(skipped, ns.Name)
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s in namespace %s: %w", what, ns.Name, err)
+		}
+	}
+	return skipped, nil
+}
@@ -0,0 +1,249 @@
+package configset_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+	"github.com/wxdao/configset/pkg/configsettest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newFakeKubeClient() crclient.Client {
+	mapper := configsettest.NewRESTMapper(clientgoscheme.Scheme)
+	mapper.Add(configset.ConfigSetGroupVersionKind, meta.RESTScopeNamespace)
+	return configsettest.NewFakeKubeClient(configsettest.FakeKubeClientOptions{RESTMapper: mapper})
+}
+
+func TestSecretSetInfoStore(t *testing.T) {
+	configsettest.TestSetInfoStore(t, func(t *testing.T) configset.SetInfoStore {
+		store, err := configset.NewSecretSetInfoStore(newFakeKubeClient(), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestConfigMapSetInfoStore(t *testing.T) {
+	configsettest.TestSetInfoStore(t, func(t *testing.T) configset.SetInfoStore {
+		store, err := configset.NewConfigMapSetInfoStore(newFakeKubeClient(), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestCRDSetInfoStore(t *testing.T) {
+	configsettest.TestSetInfoStore(t, func(t *testing.T) configset.SetInfoStore {
+		store, err := configset.NewCRDSetInfoStore(newFakeKubeClient(), "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestFileSetInfoStore(t *testing.T) {
+	configsettest.TestSetInfoStore(t, func(t *testing.T) configset.SetInfoStore {
+		store, err := configset.NewFileSetInfoStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestCRDSetInfoStoreRenameKeepsRevision(t *testing.T) {
+	ctx := context.Background()
+	kube := newFakeKubeClient()
+	store, err := configset.NewCRDSetInfoStore(kube, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.UpdateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err != nil {
+			t.Fatalf("UpdateSetInfo: %v", err)
+		}
+	}
+	cli, err := configset.NewClient(kube, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Rename(ctx, "a", "b", configset.RenameOptions{}); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	configsettest.AssertSetInfo(t, store, "a", nil)
+	configsettest.AssertSetInfo(t, store, "b", []configset.ResourceInfo{})
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(configset.ConfigSetGroupVersionKind)
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: "b"}, obj); err != nil {
+		t.Fatalf("failed to get configset b: %v", err)
+	}
+	if revision, _, _ := unstructured.NestedInt64(obj.Object, "status", "revision"); revision != 3 {
+		t.Errorf("configset b has revision %d, want 3", revision)
+	}
+}
+
+// interleavingClient runs interleave once, right before the first status
+// patch, as if another client wrote in between.
+type interleavingClient struct {
+	crclient.Client
+	interleave func()
+}
+
+func (c *interleavingClient) Status() crclient.StatusWriter {
+	return &interleavingStatusWriter{StatusWriter: c.Client.Status(), c: c}
+}
+
+type interleavingStatusWriter struct {
+	crclient.StatusWriter
+	c *interleavingClient
+}
+
+func (w *interleavingStatusWriter) Patch(ctx context.Context, obj crclient.Object, patch crclient.Patch, opts ...crclient.PatchOption) error {
+	if interleave := w.c.interleave; interleave != nil {
+		w.c.interleave = nil
+		interleave()
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func TestCRDSetInfoStoreConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	kube := newFakeKubeClient()
+	other, err := configset.NewCRDSetInfoStore(kube, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.CreateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err != nil {
+		t.Fatalf("CreateSetInfo: %v", err)
+	}
+
+	interleaving := &interleavingClient{Client: kube}
+	interleaving.interleave = func() {
+		if err := other.UpdateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err != nil {
+			t.Errorf("concurrent UpdateSetInfo: %v", err)
+		}
+	}
+	store, err := configset.NewCRDSetInfoStore(interleaving, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err != nil {
+		t.Fatalf("UpdateSetInfo: %v", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(configset.ConfigSetGroupVersionKind)
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: "a"}, obj); err != nil {
+		t.Fatalf("failed to get configset a: %v", err)
+	}
+	if revision, _, _ := unstructured.NestedInt64(obj.Object, "status", "revision"); revision != 3 {
+		t.Errorf("configset a has revision %d, want 3", revision)
+	}
+}
+
+// namespaceRestrictedClient forbids listing across namespaces and in the
+// forbidden namespaces.
+type namespaceRestrictedClient struct {
+	crclient.Client
+	forbidden map[string]bool
+}
+
+func (c *namespaceRestrictedClient) List(ctx context.Context, list crclient.ObjectList, opts ...crclient.ListOption) error {
+	listOpts := &crclient.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if _, ok := list.(*corev1.NamespaceList); !ok && (listOpts.Namespace == "" || c.forbidden[listOpts.Namespace]) {
+		return apierrors.NewForbidden(schema.GroupResource{}, "", errors.New("forbidden"))
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestListAllNamespacesSetInfos(t *testing.T) {
+	newStores := map[string]func(kube crclient.Client, namespace string) (configset.SetInfoStore, error){
+		"secret": func(kube crclient.Client, namespace string) (configset.SetInfoStore, error) {
+			return configset.NewSecretSetInfoStore(kube, namespace)
+		},
+		"configmap": func(kube crclient.Client, namespace string) (configset.SetInfoStore, error) {
+			return configset.NewConfigMapSetInfoStore(kube, namespace)
+		},
+		"crd": func(kube crclient.Client, namespace string) (configset.SetInfoStore, error) {
+			return configset.NewCRDSetInfoStore(kube, namespace)
+		},
+	}
+	for name, newStore := range newStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			kube := newFakeKubeClient()
+			for _, ns := range []string{"a", "b", "c"} {
+				if err := kube.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}); err != nil {
+					t.Fatal(err)
+				}
+				store, err := newStore(kube, ns)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.UpdateSetInfo(ctx, "set-"+ns, &configset.SetInfo{Name: "set-" + ns}); err != nil {
+					t.Fatalf("UpdateSetInfo: %v", err)
+				}
+			}
+
+			store, err := newStore(&namespaceRestrictedClient{Client: kube, forbidden: map[string]bool{"b": true}}, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			infos, skipped, err := store.(configset.AllNamespacesSetInfoLister).ListAllNamespacesSetInfos(ctx)
+			if err != nil {
+				t.Fatalf("ListAllNamespacesSetInfos: %v", err)
+			}
+			got := map[string]string{}
+			for _, info := range infos {
+				got[info.Namespace] = info.Name
+			}
+			want := map[string]string{"a": "set-a", "c": "set-c"}
+			if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(skipped, []string{"b"}) {
+				t.Errorf("got %v skipping %v, want %v skipping [b]", got, skipped, want)
+			}
+		})
+	}
+}
+
+func TestCopySetInfosRejectsSameStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src, err := configset.NewFileSetInfoStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := configset.NewFileSetInfoStore(dir + "/./")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.UpdateSetInfo(ctx, "a", &configset.SetInfo{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := configset.CopySetInfos(ctx, src, dst, configset.CopySetInfosOptions{DeleteSource: true}); err == nil {
+		t.Fatal("CopySetInfos succeeded between stores sharing a directory")
+	}
+	info, err := src.GetSetInfo(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil {
+		t.Error("set info a was deleted")
+	}
+}
@@ -1,24 +1,55 @@
 package configset
 
 import (
-	"encoding/json"
 	"fmt"
+	"strings"
 
-	"github.com/samber/lo"
 	"github.com/wxdao/configset/pkg/diffutil"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/yaml"
 )
 
 type AddObjectResultsToDifferOptions struct {
-	Prefix                                       string
-	StripManagedFields                           bool
-	StripGeneration                              bool
-	StripResourceVersion                         bool
-	StripStatus                                  bool
+	Prefix                 string
+	StripManagedFields     bool
+	StripGeneration        bool
+	StripResourceVersion   bool
+	StripUID               bool
+	StripCreationTimestamp bool
+	StripStatus            bool
+	// FixAutoscalingV2Beta2HorizontalPodAutoscaler runs the built-in
+	// HorizontalPodAutoscalerMetricsNormalizer of the normalizers, dropping the
+	// duplicated metrics the apiserver returns for autoscaling/v2beta2
+	// HorizontalPodAutoscalers.
 	FixAutoscalingV2Beta2HorizontalPodAutoscaler bool
+	// Normalizers are run on the live and updated objects,
+	// DefaultNormalizerRegistry being used if nil.
+	Normalizers       *NormalizerRegistry
+	IgnoreDifferences []IgnoreDifferencesRule
+	// ShowSecrets disables masking the values of Secrets.
+	ShowSecrets bool
+	// OwnedFieldsOnly limits the comparison to the fields applied by
+	// FieldManager, DefaultFieldOwner if empty, as recorded in the managed
+	// fields of the objects, and lists the fields whose ownership changes.
+	OwnedFieldsOnly bool
+	FieldManager    string
+}
+
+// CleanDiffOptions returns options stripping the metadata maintained by the
+// apiserver on every object and fixing its known quirks, so that diffs show
+// only meaningful changes.
+func CleanDiffOptions() AddObjectResultsToDifferOptions {
+	return AddObjectResultsToDifferOptions{
+		StripManagedFields:     true,
+		StripGeneration:        true,
+		StripResourceVersion:   true,
+		StripUID:               true,
+		StripCreationTimestamp: true,
+		FixAutoscalingV2Beta2HorizontalPodAutoscaler: true,
+	}
 }
 
 func AddObjectResultsToDiffer(results []ObjectResult, differ *diffutil.Differ, opt AddObjectResultsToDifferOptions) error {
@@ -45,90 +76,204 @@ func AddObjectResultsToDiffer(results []ObjectResult, differ *diffutil.Differ, o
 		if result.Error != nil || (result.Live == nil && result.Updated == nil) {
 			continue
 		}
+		differ.SetHeader(filename(result), describeObjectResult(result))
 
-		if result.Live != nil {
-			obj := result.Live.DeepCopyObject()
-			ac, err := meta.Accessor(obj)
-			if err != nil {
-				return fmt.Errorf("failed to get accessor for object: %w", err)
-			}
-			if opt.StripManagedFields {
-				ac.SetManagedFields(nil)
-			}
-			if opt.StripGeneration {
-				ac.SetGeneration(0)
-			}
-			if opt.StripResourceVersion {
-				ac.SetResourceVersion("")
-			}
-			if opt.StripStatus {
-				un := obj.(*unstructured.Unstructured)
-				uc := un.UnstructuredContent()
-				delete(uc, "status")
-				un.SetUnstructuredContent(uc)
-				obj = un
-			}
-
-			b, err := yaml.Marshal(obj)
-			if err != nil {
+		normalized, err := normalizeObjectResult(result, opt)
+		if err != nil {
+			return err
+		}
+		live, updated, err := normalized.yaml()
+		if err != nil {
+			return err
+		}
+		if live != nil {
+			if err := differ.AddOld(filename(result), live); err != nil {
 				return err
 			}
-			if err := differ.AddOld(filename(result), b); err != nil {
+		}
+		if updated != nil {
+			if err := differ.AddNew(filename(result), updated); err != nil {
 				return err
 			}
 		}
-		if result.Updated != nil {
-			obj := result.Updated.DeepCopyObject()
-			ac, err := meta.Accessor(obj)
-			if err != nil {
-				return fmt.Errorf("failed to get accessor for object: %w", err)
-			}
-			if opt.StripManagedFields {
-				ac.SetManagedFields(nil)
-			}
-			if opt.StripGeneration {
-				ac.SetGeneration(0)
-			}
-			if opt.StripResourceVersion {
-				ac.SetResourceVersion("")
-			}
-			if opt.StripStatus {
-				un := obj.(*unstructured.Unstructured)
-				uc := un.UnstructuredContent()
-				delete(uc, "status")
-				un.SetUnstructuredContent(uc)
-				obj = un
-			}
+	}
 
-			if opt.FixAutoscalingV2Beta2HorizontalPodAutoscaler && obj.GetObjectKind().GroupVersionKind() == schema.FromAPIVersionAndKind("autoscaling/v2beta2", "HorizontalPodAutoscaler") {
-				un := obj.(*unstructured.Unstructured)
-				uc := un.UnstructuredContent()
-				spec, ok := uc["spec"].(map[string]interface{})
-				if ok {
-					metrics, ok := spec["metrics"].([]interface{})
-					if ok {
-						newMetrics := lo.UniqBy(metrics, func(item interface{}) string {
-							b, _ := json.Marshal(item)
-							return string(b)
-						})
-
-						spec["metrics"] = newMetrics
-						uc["spec"] = spec
-						un.SetUnstructuredContent(uc)
-						obj = un
-					}
-				}
-			}
+	return nil
+}
 
-			b, err := yaml.Marshal(obj)
-			if err != nil {
-				return err
+// describeObjectResult names the object of result along with the action on it,
+// e.g. update: deployment.apps/web (namespace default).
+func describeObjectResult(result ObjectResult) string {
+	obj := result.Live
+	if obj == nil {
+		obj = result.Updated
+	}
+	return describeObject(string(result.Action), obj)
+}
+
+func describeObject(action string, obj Object) string {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind = kind + "." + strings.ToLower(gvk.Group)
+	}
+	description := fmt.Sprintf("%s: %s/%s", action, kind, obj.GetName())
+	if obj.GetNamespace() != "" {
+		description += " (namespace " + obj.GetNamespace() + ")"
+	}
+	return description
+}
+
+type normalizedObjectResult struct {
+	// live and updated are nil if absent.
+	live    runtime.Object
+	updated runtime.Object
+	// ownership is set if the fields are limited to the ones owned by
+	// fieldManager.
+	ownership    *OwnershipChanges
+	fieldManager string
+}
+
+// normalizeObjectResult returns normalized copies of the live and updated
+// objects of result.
+func normalizeObjectResult(result ObjectResult, opt AddObjectResultsToDifferOptions) (*normalizedObjectResult, error) {
+	res := &normalizedObjectResult{}
+	var err error
+	if result.Live != nil {
+		res.live, err = normalizeObject(result.Live, opt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result.Updated != nil {
+		res.updated, err = normalizeObject(result.Updated, opt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.OwnedFieldsOnly {
+		// managed fields are read from the original objects as they may be
+		// stripped by now
+		res.fieldManager = opt.FieldManager
+		if res.fieldManager == "" {
+			res.fieldManager = DefaultFieldOwner
+		}
+		before, err := ownedFields(result.Live, res.fieldManager)
+		if err != nil {
+			return nil, err
+		}
+		after, err := ownedFields(result.Updated, res.fieldManager)
+		if err != nil {
+			return nil, err
+		}
+		res.ownership = ownershipChanges(before, after, result)
+		// released fields are kept on both sides to show what happens to them
+		owned := before.Union(after)
+		if res.live != nil {
+			if res.live, err = filterOwnedFields(res.live, owned); err != nil {
+				return nil, err
 			}
-			if err := differ.AddNew(filename(result), b); err != nil {
-				return err
+		}
+		if res.updated != nil {
+			if res.updated, err = filterOwnedFields(res.updated, owned); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	return nil
+	if !opt.ShowSecrets {
+		res.live, res.updated, err = maskSecrets(res.live, res.updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// yaml marshals the live and updated objects, nil if absent. Fields released
+// by the field manager are listed in comments heading the live object, and
+// the acquired ones in comments heading the updated object.
+func (r *normalizedObjectResult) yaml() ([]byte, []byte, error) {
+	marshal := func(obj runtime.Object, owned []string) ([]byte, error) {
+		if obj == nil {
+			return nil, nil
+		}
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		var comments []byte
+		for _, path := range owned {
+			comments = append(comments, fmt.Sprintf("# owned by %s: %s\n", r.fieldManager, path)...)
+		}
+		return append(comments, b...), nil
+	}
+
+	var released, acquired []string
+	if r.ownership != nil {
+		released, acquired = r.ownership.Released, r.ownership.Acquired
+	}
+	live, err := marshal(r.live, released)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated, err := marshal(r.updated, acquired)
+	if err != nil {
+		return nil, nil, err
+	}
+	return live, updated, nil
+}
+
+// normalizeObject returns a copy of obj with the normalizations in opt applied.
+func normalizeObject(obj Object, opt AddObjectResultsToDifferOptions) (runtime.Object, error) {
+	copied := obj.DeepCopyObject()
+	ac, err := meta.Accessor(copied)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accessor for object: %w", err)
+	}
+	if opt.StripManagedFields {
+		ac.SetManagedFields(nil)
+	}
+	if opt.StripGeneration {
+		ac.SetGeneration(0)
+	}
+	if opt.StripResourceVersion {
+		ac.SetResourceVersion("")
+	}
+	if opt.StripUID {
+		ac.SetUID("")
+	}
+	if opt.StripCreationTimestamp {
+		ac.SetCreationTimestamp(metav1.Time{})
+	}
+	if opt.StripStatus {
+		un := copied.(*unstructured.Unstructured)
+		uc := un.UnstructuredContent()
+		delete(uc, "status")
+		un.SetUnstructuredContent(uc)
+		copied = un
+	}
+
+	normalizers := opt.Normalizers
+	if normalizers == nil {
+		normalizers = DefaultNormalizerRegistry
+	}
+	var skip []string
+	if !opt.FixAutoscalingV2Beta2HorizontalPodAutoscaler {
+		skip = append(skip, HorizontalPodAutoscalerMetricsNormalizer)
+	}
+	copied, err = normalizers.Normalize(copied, skip...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opt.IgnoreDifferences) > 0 {
+		copied, err = ignoreDifferences(copied, opt.IgnoreDifferences)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return copied, nil
 }
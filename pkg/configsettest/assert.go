@@ -0,0 +1,82 @@
+package configsettest
+
+import (
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+)
+
+// ExpectedObjectResult describes an expected configset.ObjectResult.
+type ExpectedObjectResult struct {
+	Action    configset.ObjectAction
+	Kind      string
+	Namespace string
+	Name      string
+	// Error tells whether the result is expected to carry an error.
+	Error bool
+}
+
+// AssertApplyResult checks that res holds exactly the expected object
+// results, in order.
+func AssertApplyResult(t testing.TB, res configset.ApplyResult, want ...ExpectedObjectResult) {
+	t.Helper()
+	AssertObjectResults(t, res.ObjectResults, want...)
+}
+
+// AssertDeleteResult checks that res holds exactly the expected object
+// results, in order.
+func AssertDeleteResult(t testing.TB, res configset.DeleteResult, want ...ExpectedObjectResult) {
+	t.Helper()
+	AssertObjectResults(t, res.ObjectResults, want...)
+}
+
+func AssertObjectResults(t testing.TB, results []configset.ObjectResult, want ...ExpectedObjectResult) {
+	t.Helper()
+	if len(results) != len(want) {
+		t.Errorf("got %d object results, want %d", len(results), len(want))
+	}
+	for i := 0; i < len(results) && i < len(want); i++ {
+		got := ExpectedObjectResult{
+			Action:    results[i].Action,
+			Kind:      results[i].Config.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: results[i].Config.GetNamespace(),
+			Name:      results[i].Config.GetName(),
+			Error:     results[i].Error != nil,
+		}
+		if got != want[i] {
+			t.Errorf("object result %d: got %+v (error: %v), want %+v", i, got, results[i].Error, want[i])
+		}
+	}
+}
+
+// AssertSetInfo checks that the store holds the set with exactly the given
+// resources, in order, ignoring their UIDs. A nil resources asserts that the
+// set doesn't exist.
+func AssertSetInfo(t testing.TB, store configset.SetInfoStore, name string, resources []configset.ResourceInfo) {
+	t.Helper()
+	info, err := store.GetSetInfo(testContext(t), name)
+	if err != nil {
+		t.Fatalf("failed to get set info %s: %v", name, err)
+	}
+	if resources == nil {
+		if info != nil {
+			t.Errorf("set info %s exists, want none", name)
+		}
+		return
+	}
+	if info == nil {
+		t.Fatalf("set info %s doesn't exist", name)
+	}
+	if len(info.Resources) != len(resources) {
+		t.Errorf("set info %s has %d resources, want %d", name, len(info.Resources), len(resources))
+	}
+	for i := 0; i < len(info.Resources) && i < len(resources); i++ {
+		got := info.Resources[i]
+		got.UID = ""
+		want := resources[i]
+		want.UID = ""
+		if got != want {
+			t.Errorf("set info %s resource %d: got %+v, want %+v", name, i, got, want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package configsettest
+
+import (
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Fake bundles a configset client with the fake cluster and the in-memory
+// store it operates on.
+type Fake struct {
+	Client *configset.Client
+	Kube   crclient.Client
+	Store  *MemorySetInfoStore
+}
+
+// NewFake creates a configset client on top of a fake cluster, see
+// NewFakeKubeClient, and an empty in-memory store.
+func NewFake(opt FakeKubeClientOptions) *Fake {
+	kube := NewFakeKubeClient(opt)
+	store := NewMemorySetInfoStore()
+	// NewClient never fails
+	cli, _ := configset.NewClient(kube, store)
+	return &Fake{
+		Client: cli,
+		Kube:   kube,
+		Store:  store,
+	}
+}
+
+// NewObject returns an unstructured object with the given identity.
+func NewObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
@@ -0,0 +1,153 @@
+package configsettest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+)
+
+// TestSetInfoStore runs the conformance suite of the configset.SetInfoStore
+// contract against stores returned by newStore, which must be empty.
+func TestSetInfoStore(t *testing.T, newStore func(t *testing.T) configset.SetInfoStore) {
+	info := func(name string, resourceNames ...string) *configset.SetInfo {
+		i := &configset.SetInfo{Name: name, UpdatedAt: "2022-01-01T00:00:00Z"}
+		for _, n := range resourceNames {
+			i.Resources = append(i.Resources, configset.ResourceInfo{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Namespace:  "default",
+				Name:       n,
+				UID:        "uid-" + n,
+			})
+		}
+		return i
+	}
+
+	t.Run("GetMissing", func(t *testing.T) {
+		store := newStore(t)
+		got, err := store.GetSetInfo(testContext(t), "missing")
+		if err != nil {
+			t.Fatalf("GetSetInfo: %v", err)
+		}
+		if got != nil {
+			t.Errorf("GetSetInfo of a missing set returned %+v, want nil", got)
+		}
+	})
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.CreateSetInfo(testContext(t), "a", info("a", "x", "y")); err != nil {
+			t.Fatalf("CreateSetInfo: %v", err)
+		}
+		assertStoredSetInfo(t, store, info("a", "x", "y"))
+	})
+
+	t.Run("CreateExisting", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.CreateSetInfo(testContext(t), "a", info("a", "x")); err != nil {
+			t.Fatalf("CreateSetInfo: %v", err)
+		}
+		if err := store.CreateSetInfo(testContext(t), "a", info("a", "y")); err == nil {
+			t.Errorf("CreateSetInfo of an existing set succeeded, want error")
+		}
+		assertStoredSetInfo(t, store, info("a", "x"))
+	})
+
+	t.Run("UpdateMissing", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.UpdateSetInfo(testContext(t), "a", info("a", "x")); err != nil {
+			t.Fatalf("UpdateSetInfo: %v", err)
+		}
+		assertStoredSetInfo(t, store, info("a", "x"))
+	})
+
+	t.Run("UpdateExisting", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.UpdateSetInfo(testContext(t), "a", info("a", "x", "y")); err != nil {
+			t.Fatalf("UpdateSetInfo: %v", err)
+		}
+		if err := store.UpdateSetInfo(testContext(t), "a", info("a", "z")); err != nil {
+			t.Fatalf("UpdateSetInfo: %v", err)
+		}
+		assertStoredSetInfo(t, store, info("a", "z"))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		store := newStore(t)
+		for _, i := range []*configset.SetInfo{info("a", "x"), info("b"), info("c", "y", "z")} {
+			if err := store.UpdateSetInfo(testContext(t), i.Name, i); err != nil {
+				t.Fatalf("UpdateSetInfo: %v", err)
+			}
+		}
+		infos, err := store.ListSetInfos(testContext(t))
+		if err != nil {
+			t.Fatalf("ListSetInfos: %v", err)
+		}
+		got := map[string]int{}
+		for _, i := range infos {
+			got[i.Name] = len(i.Resources)
+		}
+		want := map[string]int{"a": 1, "b": 0, "c": 2}
+		if len(got) != len(want) || len(infos) != len(want) {
+			t.Fatalf("ListSetInfos returned %v, want %v", got, want)
+		}
+		for name, n := range want {
+			if got[name] != n {
+				t.Errorf("ListSetInfos returned %d resources for %s, want %d", got[name], name, n)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.UpdateSetInfo(testContext(t), "a", info("a", "x")); err != nil {
+			t.Fatalf("UpdateSetInfo: %v", err)
+		}
+		if err := store.UpdateSetInfo(testContext(t), "b", info("b", "y")); err != nil {
+			t.Fatalf("UpdateSetInfo: %v", err)
+		}
+		if err := store.DeleteSetInfo(testContext(t), "a"); err != nil {
+			t.Fatalf("DeleteSetInfo: %v", err)
+		}
+		if got, err := store.GetSetInfo(testContext(t), "a"); err != nil || got != nil {
+			t.Errorf("GetSetInfo of a deleted set returned %+v, %v, want nil, nil", got, err)
+		}
+		assertStoredSetInfo(t, store, info("b", "y"))
+	})
+
+	t.Run("DeleteMissing", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.DeleteSetInfo(testContext(t), "missing"); err != nil {
+			t.Errorf("DeleteSetInfo of a missing set: %v", err)
+		}
+	})
+}
+
+func assertStoredSetInfo(t *testing.T, store configset.SetInfoStore, want *configset.SetInfo) {
+	t.Helper()
+	got, err := store.GetSetInfo(testContext(t), want.Name)
+	if err != nil {
+		t.Fatalf("GetSetInfo: %v", err)
+	}
+	if got == nil {
+		t.Fatalf("set info %s doesn't exist", want.Name)
+	}
+	if got.Name != want.Name || got.UpdatedAt != want.UpdatedAt {
+		t.Errorf("got set info %s updated at %s, want %s updated at %s", got.Name, got.UpdatedAt, want.Name, want.UpdatedAt)
+	}
+	if len(got.Resources) != len(want.Resources) {
+		t.Fatalf("set info %s has %d resources, want %d", want.Name, len(got.Resources), len(want.Resources))
+	}
+	for i := range want.Resources {
+		if got.Resources[i] != want.Resources[i] {
+			t.Errorf("set info %s resource %d: got %+v, want %+v", want.Name, i, got.Resources[i], want.Resources[i])
+		}
+	}
+}
+
+func testContext(t testing.TB) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,211 @@
+package configsettest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// ClusterScopedKinds are the built-in kinds mapped as cluster-scoped by
+// NewRESTMapper.
+var ClusterScopedKinds = []schema.GroupKind{
+	{Group: "", Kind: "Namespace"},
+	{Group: "", Kind: "Node"},
+	{Group: "", Kind: "PersistentVolume"},
+	{Group: "", Kind: "ComponentStatus"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+	{Group: "storage.k8s.io", Kind: "StorageClass"},
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"},
+	{Group: "storage.k8s.io", Kind: "CSINode"},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"},
+	{Group: "policy", Kind: "PodSecurityPolicy"},
+	{Group: "networking.k8s.io", Kind: "IngressClass"},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"},
+}
+
+// NewRESTMapper returns a RESTMapper knowing all kinds of scheme with their
+// scope, as well as CustomResourceDefinitions. Mappings for custom resources
+// can be added with Add.
+func NewRESTMapper(scheme *runtime.Scheme) *meta.DefaultRESTMapper {
+	clusterScoped := map[schema.GroupKind]bool{}
+	for _, gk := range ClusterScopedKinds {
+		clusterScoped[gk] = true
+	}
+
+	mapper := meta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups())
+	add := func(gvk schema.GroupVersionKind) {
+		scope := meta.RESTScopeNamespace
+		if clusterScoped[gvk.GroupKind()] {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(gvk, scope)
+	}
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || gvk.Kind == "WatchEvent" || (len(gvk.Kind) > 4 && gvk.Kind[len(gvk.Kind)-4:] == "List") {
+			continue
+		}
+		add(gvk)
+	}
+	add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	return mapper
+}
+
+type FakeKubeClientOptions struct {
+	// Scheme defaults to the client-go scheme.
+	Scheme *runtime.Scheme
+	// RESTMapper defaults to NewRESTMapper(Scheme).
+	RESTMapper meta.RESTMapper
+	// Objects are present in the fake cluster from the start.
+	Objects []crclient.Object
+}
+
+// NewFakeKubeClient returns a controller-runtime fake client that additionally
+// supports what configset relies on: server-side apply patches, which are
+// approximated with JSON merge patches, dry runs returning the would-be
+// objects, UID assignment and UID preconditions on delete.
+func NewFakeKubeClient(opt FakeKubeClientOptions) crclient.Client {
+	if opt.Scheme == nil {
+		opt.Scheme = clientgoscheme.Scheme
+	}
+	if opt.RESTMapper == nil {
+		opt.RESTMapper = NewRESTMapper(opt.Scheme)
+	}
+	for _, obj := range opt.Objects {
+		if obj.GetUID() == "" {
+			obj.SetUID(uuid.NewUUID())
+		}
+	}
+	return &fakeKubeClient{
+		Client: fake.NewClientBuilder().
+			WithScheme(opt.Scheme).
+			WithRESTMapper(opt.RESTMapper).
+			WithObjects(opt.Objects...).
+			Build(),
+	}
+}
+
+type fakeKubeClient struct {
+	crclient.Client
+}
+
+func (c *fakeKubeClient) Create(ctx context.Context, obj crclient.Object, opts ...crclient.CreateOption) error {
+	if obj.GetUID() == "" {
+		obj.SetUID(uuid.NewUUID())
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *fakeKubeClient) Patch(ctx context.Context, obj crclient.Object, patch crclient.Patch, opts ...crclient.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOpts := &crclient.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+	dryRun := len(patchOpts.DryRun) > 0
+
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	var live unstructured.Unstructured
+	live.SetGroupVersionKind(gvk)
+	err = c.Client.Get(ctx, crclient.ObjectKeyFromObject(obj), &live)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	var updated unstructured.Unstructured
+	if exists {
+		liveData, err := json.Marshal(live.Object)
+		if err != nil {
+			return err
+		}
+		if data, err = jsonpatch.MergePatch(liveData, data); err != nil {
+			return err
+		}
+	}
+	// decoded like the apiserver does, with integers as int64
+	if err := utiljson.Unmarshal(data, &updated.Object); err != nil {
+		return err
+	}
+	updated.SetGroupVersionKind(gvk)
+	if !exists {
+		updated.SetUID(uuid.NewUUID())
+	}
+
+	if !dryRun {
+		if exists {
+			err = c.Client.Update(ctx, &updated)
+		} else {
+			err = c.Client.Create(ctx, &updated)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = updated.Object
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(updated.Object, obj)
+}
+
+func (c *fakeKubeClient) Delete(ctx context.Context, obj crclient.Object, opts ...crclient.DeleteOption) error {
+	deleteOpts := &crclient.DeleteOptions{}
+	deleteOpts.ApplyOptions(opts)
+
+	if len(deleteOpts.DryRun) > 0 || (deleteOpts.Preconditions != nil && deleteOpts.Preconditions.UID != nil) {
+		gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+		if err != nil {
+			return err
+		}
+		var live unstructured.Unstructured
+		live.SetGroupVersionKind(gvk)
+		if err := c.Client.Get(ctx, crclient.ObjectKeyFromObject(obj), &live); err != nil {
+			return err
+		}
+		if deleteOpts.Preconditions != nil && deleteOpts.Preconditions.UID != nil && *deleteOpts.Preconditions.UID != live.GetUID() {
+			mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				return err
+			}
+			return apierrors.NewConflict(mapping.Resource.GroupResource(), obj.GetName(), fmt.Errorf("the UID in the precondition (%s) does not match the UID in record (%s)", *deleteOpts.Preconditions.UID, live.GetUID()))
+		}
+		if len(deleteOpts.DryRun) > 0 {
+			return nil
+		}
+	}
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
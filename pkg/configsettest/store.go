@@ -0,0 +1,102 @@
+// Package configsettest provides utilities for testing code built on the
+// configset package without a real cluster.
+package configsettest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wxdao/configset/pkg/configset"
+)
+
+// MemorySetInfoStore keeps set infos in memory.
+type MemorySetInfoStore struct {
+	mu    sync.Mutex
+	infos map[string][]byte
+}
+
+var _ configset.SetInfoStore = &MemorySetInfoStore{}
+
+func NewMemorySetInfoStore() *MemorySetInfoStore {
+	return &MemorySetInfoStore{
+		infos: map[string][]byte{},
+	}
+}
+
+func (s *MemorySetInfoStore) GetSetInfo(ctx context.Context, name string) (*configset.SetInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.infos[name]
+	if !ok {
+		return nil, nil
+	}
+	return decodeSetInfo(b)
+}
+
+func (s *MemorySetInfoStore) ListSetInfos(ctx context.Context) ([]*configset.SetInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.infos))
+	for name := range s.infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	infos := make([]*configset.SetInfo, 0, len(names))
+	for _, name := range names {
+		info, err := decodeSetInfo(s.infos[name])
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *MemorySetInfoStore) CreateSetInfo(ctx context.Context, name string, info *configset.SetInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.infos[name]; ok {
+		return fmt.Errorf("set info %s already exists", name)
+	}
+	return s.put(name, info)
+}
+
+func (s *MemorySetInfoStore) UpdateSetInfo(ctx context.Context, name string, info *configset.SetInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.put(name, info)
+}
+
+func (s *MemorySetInfoStore) DeleteSetInfo(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.infos, name)
+	return nil
+}
+
+// put stores an encoded copy of info so that callers can't modify stored set
+// infos in place.
+func (s *MemorySetInfoStore) put(name string, info *configset.SetInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	s.infos[name] = b
+	return nil
+}
+
+func decodeSetInfo(b []byte) (*configset.SetInfo, error) {
+	var info configset.SetInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,78 @@
+package configsettest
+
+import (
+	"testing"
+
+	"github.com/wxdao/configset/pkg/configset"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestMemorySetInfoStore(t *testing.T) {
+	TestSetInfoStore(t, func(t *testing.T) configset.SetInfoStore {
+		return NewMemorySetInfoStore()
+	})
+}
+
+func TestFakeApplyPruneAndDelete(t *testing.T) {
+	fake := NewFake(FakeKubeClientOptions{})
+	configMap := func(name string) configset.Object {
+		obj := NewObject("v1", "ConfigMap", "", name)
+		obj.Object["data"] = map[string]interface{}{"k": name}
+		return obj
+	}
+	resource := func(name string) configset.ResourceInfo {
+		return configset.ResourceInfo{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: name}
+	}
+
+	res, err := fake.Client.Apply(testContext(t), "a", []configset.Object{configMap("x"), configMap("y")}, configset.ApplyOptions{Namespace: "default"})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	AssertApplyResult(t, res,
+		ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+		ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "y"},
+	)
+	AssertSetInfo(t, fake.Store, "a", []configset.ResourceInfo{resource("x"), resource("y")})
+
+	// dry runs leave the cluster and the store as is
+	res, err = fake.Client.Apply(testContext(t), "a", []configset.Object{configMap("x")}, configset.ApplyOptions{Namespace: "default", DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	AssertApplyResult(t, res,
+		ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+		ExpectedObjectResult{Action: configset.LogObjectActionDelete, Kind: "ConfigMap", Namespace: "default", Name: "y"},
+	)
+	AssertSetInfo(t, fake.Store, "a", []configset.ResourceInfo{resource("x"), resource("y")})
+
+	res, err = fake.Client.Apply(testContext(t), "a", []configset.Object{configMap("x")}, configset.ApplyOptions{Namespace: "default"})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	AssertApplyResult(t, res,
+		ExpectedObjectResult{Action: configset.ObjectActionUpdate, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+		ExpectedObjectResult{Action: configset.LogObjectActionDelete, Kind: "ConfigMap", Namespace: "default", Name: "y"},
+	)
+	AssertSetInfo(t, fake.Store, "a", []configset.ResourceInfo{resource("x")})
+
+	live := NewObject("v1", "ConfigMap", "", "")
+	if err := fake.Kube.Get(testContext(t), types.NamespacedName{Namespace: "default", Name: "y"}, live); err == nil {
+		t.Errorf("pruned config map y still exists")
+	}
+	live = NewObject("v1", "ConfigMap", "", "")
+	if err := fake.Kube.Get(testContext(t), types.NamespacedName{Namespace: "default", Name: "x"}, live); err != nil {
+		t.Fatalf("failed to get config map x: %v", err)
+	}
+	if live.GetUID() == "" {
+		t.Errorf("config map x has no uid")
+	}
+
+	delRes, err := fake.Client.Delete(testContext(t), "a", configset.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	AssertDeleteResult(t, delRes,
+		ExpectedObjectResult{Action: configset.LogObjectActionDelete, Kind: "ConfigMap", Namespace: "default", Name: "x"},
+	)
+	AssertSetInfo(t, fake.Store, "a", nil)
+}
@@ -7,10 +7,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 )
 
 type Differ struct {
 	basedir string
+	headers map[string]string
 }
 
 func NewDiffer() (*Differ, error) {
@@ -28,6 +30,7 @@ func NewDiffer() (*Differ, error) {
 
 	return &Differ{
 		basedir: basedir,
+		headers: map[string]string{},
 	}, nil
 }
 
@@ -41,6 +44,64 @@ func (differ *Differ) AddNew(name string, data []byte) error {
 	return os.WriteFile(filepath.Join(newDir, name), data, 0600)
 }
 
+// SetHeader sets the header shown before the diff of the named file by Render.
+func (differ *Differ) SetHeader(name string, header string) {
+	differ.headers[name] = header
+}
+
+type RenderOptions struct {
+	// Context is the number of unchanged lines shown around changes.
+	Context int
+	Color   bool
+	// Headers enables the headers set with SetHeader.
+	Headers bool
+}
+
+// Render writes the unified diff of all added files to w without any external
+// program. Files missing on one side are compared as empty. It returns whether
+// any file differs.
+func (differ *Differ) Render(w io.Writer, opt RenderOptions) (bool, error) {
+	oldDir, newDir := differ.subdirs()
+	names := map[string]struct{}{}
+	for _, dir := range []string{oldDir, newDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			names[entry.Name()] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	changed := false
+	for _, name := range sorted {
+		oldData, err := readFileIfExists(filepath.Join(oldDir, name))
+		if err != nil {
+			return changed, err
+		}
+		newData, err := readFileIfExists(filepath.Join(newDir, name))
+		if err != nil {
+			return changed, err
+		}
+
+		uopt := UnifiedOptions{Context: opt.Context, Color: opt.Color}
+		if opt.Headers {
+			uopt.Header = differ.headers[name]
+		}
+		diff, err := Unified(w, "old/"+name, "new/"+name, oldData, newData, uopt)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || diff
+	}
+	return changed, nil
+}
+
 func (differ *Differ) Run(command string, stdout io.Writer, stderr io.Writer) error {
 	shell := ""
 	var args []string
@@ -72,3 +133,11 @@ func (differ *Differ) Cleanup() error {
 func (differ *Differ) subdirs() (string, string) {
 	return filepath.Join(differ.basedir, "old"), filepath.Join(differ.basedir, "new")
 }
+
+func readFileIfExists(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
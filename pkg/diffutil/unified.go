@@ -0,0 +1,334 @@
+package diffutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const DefaultContextLines = 3
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorCyan   = "\x1b[36m"
+	noNewlineAt = "\\ No newline at end of file"
+)
+
+type UnifiedOptions struct {
+	// Context is the number of unchanged lines shown around changes.
+	Context int
+	// Color enables ANSI colors in the output.
+	Color bool
+	// Header, if set, is written on its own line before the diff.
+	Header string
+}
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	// a and b are the indexes of the line in the old and new lines.
+	a, b int
+}
+
+// Unified writes the unified diff between old and new, labeled oldLabel and
+// newLabel, to w. Nothing is written if they are identical. It returns whether
+// they differ.
+func Unified(w io.Writer, oldLabel string, newLabel string, old []byte, new []byte, opt UnifiedOptions) (bool, error) {
+	if bytes.Equal(old, new) {
+		return false, nil
+	}
+	a, b := splitLines(old), splitLines(new)
+	ops := diffLines(a, b)
+
+	p := &printer{w: w, color: opt.Color}
+	if opt.Header != "" {
+		p.line(colorBold, opt.Header)
+	}
+	p.line(colorBold, "--- "+oldLabel)
+	p.line(colorBold, "+++ "+newLabel)
+
+	for _, h := range hunks(ops, opt.Context) {
+		var oldStart, oldCount, newStart, newCount int
+		oldStart, newStart = -1, -1
+		for _, o := range h {
+			if o.kind != opInsert {
+				if oldStart < 0 {
+					oldStart = o.a
+				}
+				oldCount++
+			}
+			if o.kind != opDelete {
+				if newStart < 0 {
+					newStart = o.b
+				}
+				newCount++
+			}
+		}
+		// an empty range starts at the line before it
+		if oldStart < 0 {
+			oldStart = h[0].a
+		} else {
+			oldStart++
+		}
+		if newStart < 0 {
+			newStart = h[0].b
+		} else {
+			newStart++
+		}
+		p.line(colorCyan, fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount)))
+
+		for _, o := range h {
+			switch o.kind {
+			case opEqual:
+				p.text("", " ", a[o.a])
+			case opDelete:
+				p.text(colorRed, "-", a[o.a])
+			case opInsert:
+				p.text(colorGreen, "+", b[o.b])
+			}
+		}
+	}
+	return true, p.err
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+type printer struct {
+	w     io.Writer
+	color bool
+	err   error
+}
+
+func (p *printer) line(color string, s string) {
+	if p.err != nil {
+		return
+	}
+	if p.color && color != "" {
+		s = color + s + colorReset
+	}
+	_, p.err = io.WriteString(p.w, s+"\n")
+}
+
+// text prints a line of the compared texts, which keeps its newline if any.
+func (p *printer) text(color string, prefix string, s string) {
+	if strings.HasSuffix(s, "\n") {
+		p.line(color, prefix+s[:len(s)-1])
+		return
+	}
+	p.line(color, prefix+s)
+	p.line("", noNewlineAt)
+}
+
+// splitLines splits b into lines, each keeping its trailing newline.
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxSearchCost bounds the search for the middle of an edit path. Texts that
+// differ more than that are replaced as a whole, so that diffing unrelated
+// texts doesn't take quadratic time.
+const maxSearchCost = 4096
+
+// diffLines computes the shortest edit script from a to b with the linear
+// space variant of the Myers algorithm, which splits the texts at the middle
+// of the edit path and diffs the halves recursively.
+func diffLines(a, b []string) []op {
+	d := &lineDiff{a: a, b: b, ops: make([]op, 0, len(a)+len(b))}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+type lineDiff struct {
+	a, b []string
+	ops  []op
+}
+
+// compare appends the edit script from a[aLo:aHi] to b[bLo:bHi].
+func (d *lineDiff) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, op{kind: opEqual, a: aLo, b: bLo})
+		aLo++
+		bLo++
+	}
+	// the common suffix is appended after the rest
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-1-suffix] == d.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	x, y, ok := -1, -1, false
+	if aLo < aHi && bLo < bHi {
+		x, y, ok = d.middle(aLo, aHi, bLo, bHi)
+	}
+	if ok {
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aHi, y, bHi)
+	} else {
+		for i := aLo; i < aHi; i++ {
+			d.ops = append(d.ops, op{kind: opDelete, a: i, b: bLo})
+		}
+		for j := bLo; j < bHi; j++ {
+			d.ops = append(d.ops, op{kind: opInsert, a: aHi, b: j})
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, op{kind: opEqual, a: aHi + i, b: bHi + i})
+	}
+}
+
+// middle returns a point of a shortest edit path from a[aLo:aHi] to
+// b[bLo:bHi] where the forward and reverse searches meet, or false if the
+// texts have nothing in common or the search costs more than maxSearchCost.
+func (d *lineDiff) middle(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// vf[offset+k] and vr[offset+k] are the furthest x reached on diagonal k
+	// by the forward and reverse searches, x counting from the end for the
+	// latter
+	vf, vr := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vr[i] = -1, -1
+	}
+	vf[offset+1], vr[offset+1] = 0, 0
+	delta := n - m
+	// the searches meet in the forward one if delta is odd
+	front := delta%2 != 0
+	// the diagonals that went out of bounds are skipped
+	fStart, fEnd, rStart, rEnd := 0, 0, 0, 0
+
+	for e := 0; e < maxD && e < maxSearchCost; e++ {
+		for k := -e + fStart; k <= e-fEnd; k += 2 {
+			var x int
+			if k == -e || (k != e && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				rk := offset + delta - k
+				if rk >= 0 && rk < len(vr) && vr[rk] != -1 && x >= n-vr[rk] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+		for k := -e + rStart; k <= e-rEnd; k += 2 {
+			var x int
+			if k == -e || (k != e && vr[offset+k-1] < vr[offset+k+1]) {
+				x = vr[offset+k+1]
+			} else {
+				x = vr[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vr[offset+k] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !front:
+				fk := offset + delta - k
+				if fk >= 0 && fk < len(vf) && vf[fk] != -1 {
+					fx := vf[fk]
+					fy := fx - (fk - offset)
+					if fx >= n-x {
+						return aLo + fx, bLo + fy, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// hunks groups changes with up to context unchanged lines around them,
+// merging groups whose context overlap.
+func hunks(ops []op, context int) [][]op {
+	if context < 0 {
+		context = 0
+	}
+	var result [][]op
+	start, end := -1, -1
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		lo := i - context
+		if lo < 0 {
+			lo = 0
+		}
+		hi := i + context + 1
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+		if start >= 0 {
+			result = append(result, ops[start:end])
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		result = append(result, ops[start:end])
+	}
+	return result
+}
+
+// LineStat returns the numbers of lines added and removed from old to new.
+func LineStat(old []byte, new []byte) (int, int) {
+	if bytes.Equal(old, new) {
+		return 0, 0
+	}
+	added, removed := 0, 0
+	for _, o := range diffLines(splitLines(old), splitLines(new)) {
+		switch o.kind {
+		case opInsert:
+			added++
+		case opDelete:
+			removed++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,259 @@
+package diffutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		opt     UnifiedOptions
+		want    string
+		changed bool
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			opt:  UnifiedOptions{Context: 3},
+			want: "",
+		},
+		{
+			name: "single line",
+			old:  "a\n",
+			new:  "b\n",
+			opt:  UnifiedOptions{Context: 3},
+			want: "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			name: "context",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "1\n2\n3\n4\nX\n6\n7\n8\n9\n",
+			opt:  UnifiedOptions{Context: 3},
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "1\nX\n3\n4\n5\n6\n7\n8\nY\n10\n",
+			opt:  UnifiedOptions{Context: 1},
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n@@ -8,3 +8,3 @@\n 8\n-9\n+Y\n 10\n",
+		},
+		{
+			name: "merged hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "1\nX\n3\nY\n5\n6\n7\n8\n9\n10\n",
+			opt:  UnifiedOptions{Context: 1},
+			want: "--- old\n+++ new\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n-4\n+Y\n 5\n",
+		},
+		{
+			name: "insertion without context",
+			old:  "a\nc\n",
+			new:  "a\nb\nc\n",
+			opt:  UnifiedOptions{Context: 0},
+			want: "--- old\n+++ new\n@@ -1,0 +2 @@\n+b\n",
+		},
+		{
+			name: "no newline at end of file",
+			old:  "a\nb",
+			new:  "a\nc",
+			opt:  UnifiedOptions{Context: 3},
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name: "newline added at end of file",
+			old:  "a",
+			new:  "a\n",
+			opt:  UnifiedOptions{Context: 3},
+			want: "--- old\n+++ new\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			name: "create",
+			old:  "",
+			new:  "a\nb\n",
+			opt:  UnifiedOptions{Context: 3},
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "delete",
+			old:  "a\nb\n",
+			new:  "",
+			opt:  UnifiedOptions{Context: 3},
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "header and color",
+			old:  "a\n",
+			new:  "b\n",
+			opt:  UnifiedOptions{Context: 3, Color: true, Header: "diff old new"},
+			want: colorBold + "diff old new" + colorReset + "\n" +
+				colorBold + "--- old" + colorReset + "\n" +
+				colorBold + "+++ new" + colorReset + "\n" +
+				colorCyan + "@@ -1 +1 @@" + colorReset + "\n" +
+				colorRed + "-a" + colorReset + "\n" +
+				colorGreen + "+b" + colorReset + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			changed, err := Unified(&buf, "old", "new", []byte(tt.old), []byte(tt.new), tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != (tt.want != "") {
+				t.Errorf("got changed %v, want %v", changed, tt.want != "")
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHunkRange(t *testing.T) {
+	tests := []struct {
+		start, count int
+		want         string
+	}{
+		{start: 0, count: 0, want: "0,0"},
+		{start: 3, count: 0, want: "3,0"},
+		{start: 3, count: 1, want: "3"},
+		{start: 3, count: 2, want: "3,2"},
+	}
+	for _, tt := range tests {
+		if got := hunkRange(tt.start, tt.count); got != tt.want {
+			t.Errorf("hunkRange(%d, %d) = %q, want %q", tt.start, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLineStat(t *testing.T) {
+	tests := []struct {
+		name           string
+		old, new       string
+		added, removed int
+	}{
+		{name: "identical", old: "a\nb\n", new: "a\nb\n"},
+		{name: "create", old: "", new: "a\nb\n", added: 2},
+		{name: "delete", old: "a\nb\nc\n", new: "", removed: 3},
+		{name: "change", old: "a\nb\nc\n", new: "a\nx\nc\nd\n", added: 2, removed: 1},
+		{name: "moved line", old: "a\nb\nc\n", new: "b\nc\na\n", added: 1, removed: 1},
+		{name: "no newline at end of file", old: "a", new: "a\n", added: 1, removed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := LineStat([]byte(tt.old), []byte(tt.new))
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("got +%d -%d, want +%d -%d", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+// applyOps rebuilds the new lines from the old ones and ops, failing if ops
+// isn't a valid edit script from a to b.
+func applyOps(t *testing.T, a, b []string, ops []op) {
+	t.Helper()
+	var got []string
+	i, j := 0, 0
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			if o.a != i || o.b != j || a[i] != b[j] {
+				t.Fatalf("invalid equal op %+v at %d,%d", o, i, j)
+			}
+			got = append(got, a[i])
+			i++
+			j++
+		case opDelete:
+			if o.a != i {
+				t.Fatalf("invalid delete op %+v at %d", o, i)
+			}
+			i++
+		case opInsert:
+			if o.b != j {
+				t.Fatalf("invalid insert op %+v at %d", o, j)
+			}
+			got = append(got, b[j])
+			j++
+		}
+	}
+	if i != len(a) || strings.Join(got, "") != strings.Join(b, "") {
+		t.Fatalf("edit script doesn't turn a into b")
+	}
+}
+
+func TestDiffLinesMaxSearchCost(t *testing.T) {
+	// texts differing everywhere but for one line in the middle
+	lines := func(prefix string, n int) []string {
+		var result []string
+		for i := 0; i < n; i++ {
+			if i == n/2 {
+				result = append(result, "common\n")
+			}
+			result = append(result, fmt.Sprintf("%s%d\n", prefix, i))
+		}
+		return result
+	}
+	count := func(ops []op, kind opKind) int {
+		n := 0
+		for _, o := range ops {
+			if o.kind == kind {
+				n++
+			}
+		}
+		return n
+	}
+
+	// the common line is found while the search is cheap enough
+	a, b := lines("a", 100), lines("b", 100)
+	ops := diffLines(a, b)
+	applyOps(t, a, b, ops)
+	if equal := count(ops, opEqual); equal != 1 {
+		t.Errorf("got %d equal lines, want 1", equal)
+	}
+
+	// and beyond maxSearchCost the texts are replaced as a whole
+	n := maxSearchCost + 1000
+	a, b = lines("a", n), lines("b", n)
+	ops = diffLines(a, b)
+	applyOps(t, a, b, ops)
+	if equal, deleted, inserted := count(ops, opEqual), count(ops, opDelete), count(ops, opInsert); equal != 0 || deleted != n+1 || inserted != n+1 {
+		t.Errorf("got %d equal, %d deleted and %d inserted lines, want 0, %d and %d", equal, deleted, inserted, n+1, n+1)
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{a: "", b: "", edits: 0},
+		{a: "abc", b: "abc", edits: 0},
+		{a: "abcabba", b: "cbabac", edits: 5},
+		{a: "abc", b: "", edits: 3},
+		{a: "", b: "abc", edits: 3},
+		{a: "abcdef", b: "xbcdey", edits: 4},
+		{a: "aaaa", b: "aa", edits: 2},
+	}
+	for _, tt := range tests {
+		a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+		ops := diffLines(a, b)
+		applyOps(t, a, b, ops)
+		edits := 0
+		for _, o := range ops {
+			if o.kind != opEqual {
+				edits++
+			}
+		}
+		if edits != tt.edits {
+			t.Errorf("diffLines(%q, %q) has %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+	}
+}